@@ -0,0 +1,88 @@
+package apis
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/requestid"
+	. "github.com/xdtest/project/models"
+)
+
+// audit 记录一条对用户的操作，操作人取自token，没有token时记为匿名
+// 修改用户的操作把 auditEntry 传给 models，和修改在同一个事务里写，不要在之后调用这个
+func audit(c *gin.Context, action string, targetId int, before, after interface{}) {
+	if err := AppendAudit(auditEntry(c, action, targetId), before, after); err != nil {
+		log.Println("append audit log error", err)
+	}
+}
+
+// auditEntry 按当前请求填好操作人、组织的审计日志，修改前后的数据由 models 填
+func auditEntry(c *gin.Context, action string, targetId int) *AuditLog {
+	entry := &AuditLog{
+		Action:     action,
+		TargetType: "user",
+		TargetId:   targetId,
+		RequestId:  requestid.Get(c),
+		ActorName:  "anonymous",
+	}
+	if v, ok := c.Get("claims"); ok {
+		claims := v.(*jwt.CustomClaims)
+		entry.TenantId = claims.Tenant
+		entry.ActorId = claims.ID
+		entry.ActorName = claims.Name
+		if claims.ImpersonatorId != 0 { //模拟登录时记录真正的操作人
+			entry.ActorId = claims.ImpersonatorId
+			entry.ActorName = claims.ImpersonatorName + " as " + claims.Name
+		}
+	}
+	return entry
+}
+
+// Auditlogs 按操作人、目标、动作、时间查询当前组织的审计日志
+// 时间参数使用RFC3339格式，如 2006-01-02T15:04:05+08:00
+func Auditlogs(c *gin.Context) {
+	var f AuditFilter
+	f.TenantId = claimsTenant(c)
+	f.ActorId, _ = strconv.Atoi(c.Query("actor"))
+	f.TargetId, _ = strconv.Atoi(c.Query("target"))
+	f.Action = c.Query("action")
+	f.Since, _ = time.Parse(time.RFC3339, c.Query("since"))
+	f.Until, _ = time.Parse(time.RFC3339, c.Query("until"))
+	f.Limit, _ = strconv.Atoi(c.Query("limit"))
+	f.Offset, _ = strconv.Atoi(c.Query("offset"))
+	logs, err := QueryAuditLogs(f)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   logs,
+	})
+}
+
+// Verifyauditlogs 校验审计日志的hash链是否完整
+func Verifyauditlogs(c *gin.Context) {
+	badId, checked, err := VerifyAuditChain()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if badId != 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "审计日志被篡改",
+			"data":   gin.H{"checked": checked, "bad_id": badId},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "审计日志完整",
+		"data":   gin.H{"checked": checked},
+	})
+}
@@ -0,0 +1,94 @@
+package apis
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/bulk"
+	"github.com/xdtest/project/middleware/errhandler"
+	. "github.com/xdtest/project/models"
+)
+
+// MaxImportSize 导入文件的大小上限
+var MaxImportSize int64 = 32 << 20
+
+// Importusers POST /v1/admin/import/users 批量导入用户到当前组织
+// 请求体直接是文件内容，或者 multipart 表单的 file 字段
+// 查询参数 format=csv|jsonl（不写时按文件名、Content-Type 判断）、mode=skip|upsert、dry_run=true、batch_size
+func Importusers(c *gin.Context) {
+	mode := c.DefaultQuery("mode", ImportSkip)
+	if mode != ImportSkip && mode != ImportUpsert {
+		c.Error(errhandler.New(http.StatusBadRequest, "mode 只能是 skip 或 upsert"))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	batchSize, _ := strconv.Atoi(c.Query("batch_size"))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportSize)
+	var body io.Reader = c.Request.Body
+	format := bulk.Format(c.Query("format"))
+	if c.ContentType() == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.Error(errhandler.New(http.StatusBadRequest, "请上传文件 file"))
+			return
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = formatOf(header)
+		}
+	} else if format == "" {
+		format = bulk.Format(c.ContentType())
+	}
+	if format == "" {
+		c.Error(errhandler.New(http.StatusUnsupportedMediaType, "无法识别文件格式，请用 format=csv 或 format=jsonl"))
+		return
+	}
+
+	tenantId := claimsTenant(c)
+	report, err := bulk.Import(body, format, bulk.Options{
+		TenantId:  tenantId,
+		Mode:      mode,
+		DryRun:    dryRun,
+		BatchSize: batchSize,
+		Audit:     auditEntry(c, "", 0), //动作和目标按每一行填
+	})
+	if err != nil {
+		c.Error(errhandler.New(http.StatusBadRequest, "读取文件失败: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   report,
+	})
+}
+
+// formatOf 按上传文件的文件名或 Content-Type 判断格式
+func formatOf(header *multipart.FileHeader) string {
+	if format := bulk.Format(filepath.Ext(header.Filename)); format != "" {
+		return format
+	}
+	return bulk.Format(header.Header.Get("Content-Type"))
+}
+
+// Exportusers GET /v1/admin/export/users?format=csv|jsonl 导出当前组织的用户，边查边写
+func Exportusers(c *gin.Context) {
+	format := bulk.Format(c.DefaultQuery("format", bulk.CSV))
+	if format == "" {
+		c.Error(errhandler.New(http.StatusBadRequest, "format 只能是 csv 或 jsonl"))
+		return
+	}
+	name := "users-" + time.Now().Format("20060102") + "." + format
+	c.Header("Content-Type", bulk.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Status(http.StatusOK)
+	if _, err := bulk.Export(c.Writer, format, claimsTenant(c)); err != nil {
+		c.Error(err) //响应已经开始写，只能记日志
+	}
+}
@@ -0,0 +1,65 @@
+package apis
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/mailer"
+	. "github.com/xdtest/project/models"
+)
+
+// VerifyLinkBase 验证邮件里链接的前缀
+var VerifyLinkBase = "http://127.0.0.1:8000/verify-email"
+
+// SendVerificationMail 生成验证令牌并发邮件，gRPC 接口也用它
+func SendVerificationMail(user User) error {
+	token, err := user.CreateEmailVerification()
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s 你好，点击下面的链接验证邮箱，%d小时内有效：\n%s?token=%s",
+		user.Name, int(VerifyTokenTTL.Hours()), VerifyLinkBase, token)
+	return mailer.Send(user.GetEmail(), "验证邮箱", body)
+}
+
+// Verifyemail 打开邮件里的链接完成验证
+func Verifyemail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "缺少token",
+		})
+		return
+	}
+	if _, err := VerifyEmail(token, auditEntry(c, AuditUserUpdate, 0)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "邮箱验证成功",
+	})
+}
+
+// Resendverification 重新发送验证邮件，不暴露账号是否存在
+func Resendverification(c *gin.Context) {
+	account := c.PostForm("account")
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	user, err := FindByAccount(tenant, account)
+	if err == nil && user.GetEmail() != "" && !user.EmailVerified() {
+		// 发送太频繁时也不报错，否则能从429看出账号存在
+		if err := SendVerificationMail(user); err != nil {
+			log.Println("send verification mail error", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "如果账号存在且邮箱未验证，验证邮件已重新发送",
+	})
+}
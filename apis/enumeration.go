@@ -0,0 +1,13 @@
+package apis
+
+// VerboseAuthErrors 为true时登录、注册失败会区分"用户不存在""用户名已存在"等原因
+// 对外部署时保持false，统一返回模糊的提示，并用 security.PadTiming 补齐耗时，避免被用来探测账号是否存在
+var VerboseAuthErrors = false
+
+// 统一的失败提示
+const (
+	msgLoginFailed    = "用户名或密码错误"
+	msgRegisterQueued = "注册申请已受理，结果已发送到邮箱"
+	// 没有邮箱时成功和用户名重复都返回这个，只有知道密码的注册人能登录确认
+	msgRegisterSubmitted = "注册申请已受理，如果用户名可用，可以用该用户名和密码登录"
+)
@@ -0,0 +1,46 @@
+package apis
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/negotiate"
+	. "github.com/xdtest/project/models"
+)
+
+// 业务错误对应的状态码和提示，handler 直接 c.Error 返回即可
+func init() {
+	errhandler.Register(
+		errhandler.Is(negotiate.ErrNotAcceptable, http.StatusNotAcceptable, "不支持的 Accept，可用 "+strings.Join(negotiate.Offered, ", ")),
+		errhandler.Is(negotiate.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "不支持的 Content-Type，可用 "+strings.Join(negotiate.Offered, ", ")),
+		errhandler.Is(negotiate.ErrRequestTooLarge, http.StatusRequestEntityTooLarge, "请求体太大"),
+		errhandler.Is(ErrStaleVersion, http.StatusPreconditionFailed, "资料已被其他人修改，请刷新后重试"),
+		errhandler.Is(ErrSessionRevoked, http.StatusNotFound, "会话不存在"),
+		errhandler.Is(ErrNotMember, http.StatusForbidden, "不是该组织的成员"),
+		errhandler.Is(ErrNotInvited, http.StatusForbidden, "只能修改本组织成员的角色，或者加入收到本组织邀请的用户"),
+		errhandler.Is(ErrTooManyResets, http.StatusTooManyRequests, "申请过于频繁，请稍后再试"),
+		errhandler.Is(ErrTooManyMagicLink, http.StatusTooManyRequests, "申请过于频繁，请稍后再试"),
+		errhandler.Is(ErrTooManyVerifyMails, http.StatusTooManyRequests, "发送过于频繁，请稍后再试"),
+		errhandler.Is(ErrResetTokenInvalid, http.StatusBadRequest, "链接无效或已过期"),
+		errhandler.Is(ErrVerifyTokenInvalid, http.StatusBadRequest, "链接无效或已过期"),
+		errhandler.Is(ErrMagicLinkInvalid, http.StatusBadRequest, "链接无效或已过期"),
+		errhandler.Is(ErrInvitationInvalid, http.StatusBadRequest, "邀请无效或已过期"),
+		errhandler.Is(ErrInvalidEmail, http.StatusUnprocessableEntity, "邮箱格式不正确"),
+		func(err error) (int, string, bool) {
+			if msg, ok := passwordErrorMsg(err); ok {
+				return http.StatusUnprocessableEntity, msg, true
+			}
+			return 0, "", false
+		},
+	)
+}
+
+// decodeError 读请求体出错时返回的错误：格式不支持、请求体太大的原样返回，其它的是400和 msg
+func decodeError(err error, msg string) error {
+	if errors.Is(err, negotiate.ErrUnsupportedMediaType) || errors.Is(err, negotiate.ErrRequestTooLarge) {
+		return err
+	}
+	return errhandler.New(http.StatusBadRequest, msg)
+}
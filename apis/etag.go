@@ -0,0 +1,74 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/errhandler"
+	. "github.com/xdtest/project/models"
+)
+
+// userETag 用户的ETag，由版本号生成，任何修改都会让它变化
+func userETag(u User) string {
+	return `"v` + strconv.Itoa(u.Version) + `"`
+}
+
+// parseETag 从 "v3" 取出版本号，不是我们生成的ETag时返回0
+func parseETag(tag string) int {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) {
+		return 0
+	}
+	v, _ := strconv.Atoi(tag[2 : len(tag)-1])
+	return v
+}
+
+// notModified 写上ETag头，If-None-Match 命中时返回304，调用方不用再写响应体
+func notModified(c *gin.Context, u User) bool {
+	etag := userETag(u)
+	c.Header("ETag", etag)
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/") //If-None-Match 用弱比较
+		if tag == etag || tag == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion 修改前必须带 If-Match，返回客户端读到的版本号
+// 没带返回428，格式不对返回412；"*" 表示不检查版本，返回0
+func ifMatchVersion(c *gin.Context, current func() (User, error)) (int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, errhandler.New(http.StatusPreconditionRequired, "请先获取资料，并在 If-Match 头中带上 ETag")
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		if v := parseETag(tag); v != 0 {
+			versions = append(versions, v)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return 0, ErrStaleVersion
+	case 1:
+		return versions[0], nil
+	}
+	user, err := current() //带了多个ETag时，当前版本是其中之一就算匹配
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range versions {
+		if v == user.Version {
+			return v, nil
+		}
+	}
+	return 0, ErrStaleVersion
+}
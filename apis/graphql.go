@@ -0,0 +1,212 @@
+package apis
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/dataloader"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/xdtest/project/graph"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/policy"
+	"github.com/xdtest/project/middleware/requestid"
+	. "github.com/xdtest/project/models"
+)
+
+// GraphqlImpersonationAllowlist 模拟登录时允许调用的 mutation，和 jwt.ImpersonationAllowlist 对应
+// 没有列出的 mutation 一律拒绝，查询不受限制
+var GraphqlImpersonationAllowlist = map[string]bool{}
+
+// GraphqlRequest POST /graphql 的请求体
+type GraphqlRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Graphql POST /graphql 按 GraphQL 规范返回 data 和 errors
+// 先解析、校验，检查深度和复杂度，通过后才执行
+func Graphql(c *gin.Context) {
+	var req GraphqlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		graphqlFail(c, "请求体必须是包含 query 的 JSON")
+		return
+	}
+	if graph.MaxQueryLength > 0 && len(req.Query) > graph.MaxQueryLength {
+		graphqlFail(c, "查询太长")
+		return
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if result := graphql.ValidateDocument(&graphqlSchema, doc, nil); !result.IsValid {
+		c.JSON(http.StatusBadRequest, graphql.Result{Errors: result.Errors})
+		return
+	}
+	if err := graph.Check(graphqlSchema, doc, req.OperationName, req.Variables); err != nil {
+		graphqlFail(c, err.Error())
+		return
+	}
+	c.Set("graphql_loaders", newGraphqlLoaders())
+	c.JSON(http.StatusOK, graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphqlSchema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       c,
+	}))
+}
+
+func graphqlFail(c *gin.Context, msg string) {
+	c.JSON(http.StatusBadRequest, graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(msg)}})
+}
+
+// graphqlError 返回给客户端的错误，extensions 里带上对应的 HTTP 状态码
+type graphqlError struct {
+	status int
+	msg    string
+}
+
+func (e *graphqlError) Error() string {
+	return e.msg
+}
+
+func (e *graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"status": e.status}
+}
+
+// toGraphqlError 业务错误的状态码和提示沿用 errhandler 的映射，服务端错误只返回笼统的提示
+func toGraphqlError(c *gin.Context, err error) error {
+	status, msg := errhandler.Resolve(err)
+	if status >= 500 {
+		log.Printf("graphql error request_id=%s error=%q", requestid.Get(c), err.Error())
+	}
+	return &graphqlError{status: status, msg: msg}
+}
+
+// resolveContext resolver 里取回请求的 gin.Context 和当前用户
+func resolveContext(p graphql.ResolveParams) (*gin.Context, *jwt.CustomClaims) {
+	c := p.Context.(*gin.Context)
+	return c, c.MustGet("claims").(*jwt.CustomClaims)
+}
+
+// ownerOr 字段级权限：只能看自己的，拥有 perm 权限的可以看任何人的，没有权限时这个字段为 null 并返回错误
+func ownerOr(perm string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		_, claims := resolveContext(p)
+		if !policy.Allowed(claims, perm, p.Source.(User).Id) {
+			return nil, &graphqlError{status: http.StatusForbidden, msg: "无权限查看 " + p.Info.FieldName}
+		}
+		return resolve(p)
+	}
+}
+
+// requirePermission 查询、mutation 的权限，和 policy.RequireOwnerOr 一样，targetArg 为空时必须有 perm 权限
+func requirePermission(perm, targetArg string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	resolve = catchErrors(resolve)
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		_, claims := resolveContext(p)
+		target, _ := p.Args[targetArg].(int)
+		if !policy.Allowed(claims, perm, target) {
+			return nil, &graphqlError{status: http.StatusForbidden, msg: "无权限操作该用户"}
+		}
+		return resolve(p)
+	}
+}
+
+// denyImpersonation 模拟登录时只能调用 GraphqlImpersonationAllowlist 里的 mutation，schema 里每个 mutation 都套上
+func denyImpersonation(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		_, claims := resolveContext(p)
+		if claims.ImpersonatorId != 0 && !GraphqlImpersonationAllowlist[p.Info.FieldName] {
+			return nil, &graphqlError{status: http.StatusForbidden, msg: "模拟登录时不允许该操作"}
+		}
+		return resolve(p)
+	}
+}
+
+// catchErrors 把 resolver 返回的业务错误转换成 graphqlError
+func catchErrors(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		data, err := resolve(p)
+		if err != nil {
+			if _, ok := err.(*graphqlError); !ok {
+				c, _ := resolveContext(p)
+				err = toGraphqlError(c, err)
+			}
+			return nil, err
+		}
+		return data, nil
+	}
+}
+
+// graphqlLoaders 每个请求一组，把同一层里对多个用户的查询合并成一次
+type graphqlLoaders struct {
+	sessions       *dataloader.Loader
+	securityEvents *dataloader.Loader
+}
+
+// graphqlEventLimit securityEvents 每个用户最多返回的条数
+const graphqlEventLimit = 50
+
+func newGraphqlLoaders() *graphqlLoaders {
+	return &graphqlLoaders{
+		sessions: dataloader.NewBatchedLoader(func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+			ids := loaderIds(keys)
+			sessions, err := ListSessionsByUsers(ids)
+			results := make([]*dataloader.Result, len(ids))
+			for i, id := range ids {
+				results[i] = &dataloader.Result{Data: sessions[id], Error: err}
+			}
+			return results
+		}, dataloader.WithBatchCapacity(maxGraphqlPage)),
+		securityEvents: dataloader.NewBatchedLoader(func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+			ids := loaderIds(keys)
+			events, err := RecentSecurityEvents(ids, graphqlEventLimit)
+			results := make([]*dataloader.Result, len(ids))
+			for i, id := range ids {
+				results[i] = &dataloader.Result{Data: events[id], Error: err}
+			}
+			return results
+		}, dataloader.WithBatchCapacity(maxGraphqlPage)),
+	}
+}
+
+// loadByUser 按 Source 用户的id加载，返回 graphql 能识别的 thunk
+// 同一层的字段都解析完之后才会调用 thunk，这时这一层的id已经合并成一次查询
+func loadByUser(p graphql.ResolveParams, loader *dataloader.Loader, convert func(data interface{}) interface{}) (interface{}, error) {
+	c, _ := resolveContext(p)
+	thunk := loader.Load(c, dataloader.StringKey(strconv.Itoa(p.Source.(User).Id)))
+	return func() (interface{}, error) {
+		data, err := thunk()
+		if err != nil {
+			return nil, toGraphqlError(c, err)
+		}
+		return convert(data), nil
+	}, nil
+}
+
+func graphqlLoadersFrom(p graphql.ResolveParams) *graphqlLoaders {
+	c, _ := resolveContext(p)
+	return c.MustGet("graphql_loaders").(*graphqlLoaders)
+}
+
+func loaderIds(keys dataloader.Keys) []int {
+	ids := make([]int, len(keys))
+	for i, key := range keys {
+		ids[i], _ = strconv.Atoi(key.String())
+	}
+	return ids
+}
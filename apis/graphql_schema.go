@@ -0,0 +1,272 @@
+package apis
+
+import (
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/xdtest/project/middleware/policy"
+	. "github.com/xdtest/project/models"
+)
+
+// users 查询每页最多的条数
+const maxGraphqlPage = 500
+
+// graphqlSchema POST /graphql 的 schema，查询和 mutation 和 /v1/users 的 REST 接口对应
+var graphqlSchema = newGraphqlSchema()
+
+// userField User 的普通字段，Source 是 models.User
+func userField(t graphql.Output, get func(u User) interface{}) *graphql.Field {
+	return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(User)), nil
+	}}
+}
+
+func newGraphqlSchema() graphql.Schema {
+	sessionType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Session",
+		Description: "登录中的设备",
+		Fields: graphql.Fields{
+			"id":         {Type: graphql.NewNonNull(graphql.Int)},
+			"deviceName": {Type: graphql.NewNonNull(graphql.String)},
+			"userAgent":  {Type: graphql.NewNonNull(graphql.String)},
+			"ip":         {Type: graphql.NewNonNull(graphql.String)},
+			"createdAt":  {Type: graphql.NewNonNull(graphql.DateTime)},
+			"lastSeenAt": {Type: graphql.NewNonNull(graphql.DateTime)},
+			"current":    {Type: graphql.NewNonNull(graphql.Boolean), Description: "是不是这次请求用的会话"},
+		},
+	})
+	securityEventType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "SecurityEvent",
+		Description: "登录记录和安全事件",
+		Fields: graphql.Fields{
+			"id":        {Type: graphql.NewNonNull(graphql.Int)},
+			"type":      {Type: graphql.NewNonNull(graphql.String)},
+			"account":   {Type: graphql.NewNonNull(graphql.String)},
+			"ip":        {Type: graphql.NewNonNull(graphql.String)},
+			"userAgent": {Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": {Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "当前组织的用户",
+		Fields: graphql.Fields{
+			"id":   userField(graphql.NewNonNull(graphql.Int), func(u User) interface{} { return u.Id }),
+			"name": userField(graphql.NewNonNull(graphql.String), func(u User) interface{} { return u.Name }),
+			"email": {
+				Type:        graphql.String,
+				Description: "只有自己和管理员能看",
+				Resolve: ownerOr(policy.PermUserReadAny, func(p graphql.ResolveParams) (interface{}, error) {
+					if u := p.Source.(User); u.Email != nil {
+						return *u.Email, nil
+					}
+					return nil, nil
+				}),
+			},
+			"emailVerified":   userField(graphql.NewNonNull(graphql.Boolean), func(u User) interface{} { return u.EmailVerified() }),
+			"emailVerifiedAt": userField(graphql.DateTime, func(u User) interface{} { return u.EmailVerifiedAt }),
+			"role":            userField(graphql.NewNonNull(graphql.Int), func(u User) interface{} { return u.Role }),
+			"roleName":        userField(graphql.NewNonNull(graphql.String), func(u User) interface{} { return RoleNames[u.Role] }),
+			"tenantId":        userField(graphql.NewNonNull(graphql.Int), func(u User) interface{} { return u.TenantId }),
+			"status":          userField(graphql.NewNonNull(graphql.String), func(u User) interface{} { return u.Status }),
+			"version":         userField(graphql.NewNonNull(graphql.Int), func(u User) interface{} { return u.Version }),
+			"sessions": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(sessionType))),
+				Description: "登录中的设备，只有自己和管理员能看",
+				Resolve: ownerOr(policy.PermSessionReadAny, func(p graphql.ResolveParams) (interface{}, error) {
+					c, _ := resolveContext(p)
+					current, _ := c.Get("session")
+					return loadByUser(p, graphqlLoadersFrom(p).sessions, func(data interface{}) interface{} {
+						sessions, _ := data.([]Session)
+						nodes := make([]map[string]interface{}, 0, len(sessions))
+						for _, s := range sessions {
+							nodes = append(nodes, map[string]interface{}{
+								"id":         s.Id,
+								"deviceName": s.DeviceName,
+								"userAgent":  s.UserAgent,
+								"ip":         s.IP,
+								"createdAt":  s.CreatedAt,
+								"lastSeenAt": s.LastSeenAt,
+								"current":    current != nil && current.(Session).Id == s.Id,
+							})
+						}
+						return nodes
+					})
+				}),
+			},
+			"securityEvents": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(securityEventType))),
+				Description: "最近的安全事件，按时间倒序，只有自己和管理员能看",
+				Args: graphql.FieldConfigArgument{
+					"first": {Type: graphql.Int, DefaultValue: 20, Description: "最多返回几条，不超过50"},
+				},
+				Resolve: ownerOr(policy.PermSecurityEventReadAny, func(p graphql.ResolveParams) (interface{}, error) {
+					first, _ := p.Args["first"].(int)
+					if first < 0 || first > graphqlEventLimit {
+						return nil, &graphqlError{status: http.StatusUnprocessableEntity, msg: "first 必须在0到50之间"}
+					}
+					return loadByUser(p, graphqlLoadersFrom(p).securityEvents, func(data interface{}) interface{} {
+						events, _ := data.([]SecurityEvent)
+						if len(events) > first {
+							events = events[:first]
+						}
+						nodes := make([]map[string]interface{}, 0, len(events))
+						for _, e := range events {
+							nodes = append(nodes, map[string]interface{}{
+								"id":        e.Id,
+								"type":      e.Type,
+								"account":   e.Account,
+								"ip":        e.IP,
+								"userAgent": e.UserAgent,
+								"createdAt": e.CreatedAt,
+							})
+						}
+						return nodes
+					})
+				}),
+			},
+		},
+	})
+	userInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UserInput",
+		Description: "新建、修改用户的字段，没带的字段不修改，email 为空字符串表示清空",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":     {Type: graphql.String},
+			"password": {Type: graphql.String},
+			"email":    {Type: graphql.String},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": {
+				Type:        userType,
+				Description: "当前登录的用户，对应 GET /v1/me",
+				Resolve: catchErrors(func(p graphql.ResolveParams) (interface{}, error) {
+					_, claims := resolveContext(p)
+					user, err := GetTenantUser(claims.Tenant, claims.ID)
+					if err != nil {
+						return nil, notFound(err, "用户不存在")
+					}
+					return user, nil
+				}),
+			},
+			"user": {
+				Type:        userType,
+				Description: "查看用户，对应 GET /v1/users/{id}",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: requirePermission(policy.PermUserReadAny, "id", func(p graphql.ResolveParams) (interface{}, error) {
+					_, claims := resolveContext(p)
+					user, err := GetTenantUser(claims.Tenant, p.Args["id"].(int))
+					if err != nil {
+						return nil, notFound(err, "用户不存在")
+					}
+					return user, nil
+				}),
+			},
+			"users": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType))),
+				Description: "当前组织的用户，按id排序，对应 GET /v1/users",
+				Args: graphql.FieldConfigArgument{
+					"first": {Type: graphql.Int, DefaultValue: 50, Description: "每页条数，不超过500"},
+					"after": {Type: graphql.Int, DefaultValue: 0, Description: "上一页最后一个用户的id"},
+				},
+				Resolve: requirePermission(policy.PermUserReadAny, "", func(p graphql.ResolveParams) (interface{}, error) {
+					_, claims := resolveContext(p)
+					first, _ := p.Args["first"].(int)
+					after, _ := p.Args["after"].(int)
+					if first < 0 || first > maxGraphqlPage {
+						return nil, &graphqlError{status: http.StatusUnprocessableEntity, msg: "first 必须在0到500之间"}
+					}
+					return ListusersAfter(claims.Tenant, after, first)
+				}),
+			},
+		},
+	})
+
+	mutations := graphql.Fields{
+		"createUser": {
+			Type:        userType,
+			Description: "在当前组织新建用户，对应 POST /v1/users",
+			Args: graphql.FieldConfigArgument{
+				"input": {Type: graphql.NewNonNull(userInput)},
+			},
+			Resolve: requirePermission(policy.PermUserCreateAny, "", func(p graphql.ResolveParams) (interface{}, error) {
+				c, _ := resolveContext(p)
+				return createUser(c, p.Args["input"].(map[string]interface{}))
+			}),
+		},
+		"updateUser": {
+			Type:        userType,
+			Description: "修改用户资料，version 是读取时的版本，必须大于0，对应 PATCH /v1/users/{id}",
+			Args: graphql.FieldConfigArgument{
+				"id":      {Type: graphql.NewNonNull(graphql.Int)},
+				"version": {Type: graphql.NewNonNull(graphql.Int)},
+				"input":   {Type: graphql.NewNonNull(userInput)},
+			},
+			Resolve: requirePermission(policy.PermUserUpdateAny, "id", func(p graphql.ResolveParams) (interface{}, error) {
+				c, claims := resolveContext(p)
+				if EmailPolicy == EmailPolicyLimited && !claims.EmailVerified {
+					return nil, &graphqlError{status: http.StatusForbidden, msg: "邮箱未验证，无权限访问"}
+				}
+				if p.Args["version"].(int) <= 0 { //和 If-Match 一样必须带，0在 Updatefields 里表示不检查
+					return nil, &graphqlError{status: http.StatusPreconditionRequired, msg: "请带上 version，避免覆盖别人的修改"}
+				}
+				fields := p.Args["input"].(map[string]interface{})
+				if name, ok := fields["name"]; ok && name == "" {
+					return nil, &graphqlError{status: http.StatusUnprocessableEntity, msg: "用户名不能为空"}
+				}
+				user, err := updateUser(c, p.Args["id"].(int), p.Args["version"].(int), fields)
+				if err != nil {
+					return nil, notFound(err, "用户不存在")
+				}
+				return user, nil
+			}),
+		},
+		"deleteUser": {
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "删除用户，version 是读取时的版本，必须大于0，对应 DELETE /v1/users/{id}",
+			Args: graphql.FieldConfigArgument{
+				"id":      {Type: graphql.NewNonNull(graphql.Int)},
+				"version": {Type: graphql.NewNonNull(graphql.Int)},
+			},
+			Resolve: requirePermission(policy.PermUserDeleteAny, "id", func(p graphql.ResolveParams) (interface{}, error) {
+				c, _ := resolveContext(p)
+				if p.Args["version"].(int) <= 0 {
+					return nil, &graphqlError{status: http.StatusPreconditionRequired, msg: "请带上 version，避免删除别人刚修改的数据"}
+				}
+				if _, err := deleteUser(c, p.Args["id"].(int), p.Args["version"].(int)); err != nil {
+					return nil, notFound(err, "用户不存在")
+				}
+				return true, nil
+			}),
+		},
+		"revokeSession": {
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "退出自己的某个设备，对应 DELETE /v1/sessions/{id}",
+			Args: graphql.FieldConfigArgument{
+				"id": {Type: graphql.NewNonNull(graphql.Int)},
+			},
+			Resolve: catchErrors(func(p graphql.ResolveParams) (interface{}, error) {
+				_, claims := resolveContext(p)
+				if err := RevokeSession(claims.ID, p.Args["id"].(int)); err != nil {
+					return nil, err
+				}
+				return true, nil
+			}),
+		},
+	}
+	for _, field := range mutations { //新加的 mutation 也不会漏掉模拟登录的检查
+		field.Resolve = denyImpersonation(field.Resolve)
+	}
+	mutation := graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutations})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
@@ -0,0 +1,40 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+)
+
+// ImpersonationTTL 模拟登录token的有效秒数
+var ImpersonationTTL int64 = 15 * 60
+
+// Impersonate 管理员模拟登录同组织的某个用户，签发一个短时token
+// token里同时有被模拟的用户和真正的操作人，整个过程写审计日志
+func Impersonate(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, err := GetTenantUser(claims.Tenant, id)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
+		return
+	}
+	if user.Id == claims.ID {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "不能模拟自己",
+		})
+		return
+	}
+	user.TenantId = claims.Tenant
+	// 没有审计记录就不能模拟登录，写失败时不签发token
+	if err := AppendAudit(auditEntry(c, AuditImpersonateStart, user.Id), nil, gin.H{"ttl": ImpersonationTTL}); err != nil {
+		c.Error(err)
+		return
+	}
+	issueToken(c, user, ImpersonationTTL, claims)
+}
@@ -0,0 +1,138 @@
+package apis
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+)
+
+// InviteLinkBase 邀请邮件里链接的前缀
+var InviteLinkBase = "http://127.0.0.1:8000/invitations/accept"
+
+// Createinvitation 管理员邀请用户加入当前组织，注册后的角色在这里指定
+func Createinvitation(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	inv := Invitation{
+		TenantId:  claims.Tenant,
+		Email:     c.PostForm("email"),
+		InvitedBy: claims.ID,
+	}
+	role, err := strconv.Atoi(c.DefaultPostForm("role", "0"))
+	if _, ok := RoleNames[role]; err != nil || !ok {
+		c.Error(errhandler.New(http.StatusUnprocessableEntity, "角色不存在"))
+		return
+	}
+	inv.Role = role
+	if inv.Email == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "请填写邮箱",
+		})
+		return
+	}
+	token, err := inv.CreateInvitation()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	body := fmt.Sprintf("%s 邀请你加入，点击下面的链接完成注册，%d天内有效：\n%s?token=%s",
+		claims.Name, int(InvitationTTL.Hours()/24), InviteLinkBase, token)
+	if err := mailer.Send(inv.Email, "注册邀请", body); err != nil {
+		log.Println("send invitation mail error", err)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "邀请已发送",
+		"data":   inv,
+	})
+}
+
+// Listinvitations 当前组织发出的邀请
+func Listinvitations(c *gin.Context) {
+	list, err := ListInvitations(claimsTenant(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   list,
+	})
+}
+
+// Acceptinvitation 接受邀请，设置用户名和密码后创建用户
+func Acceptinvitation(c *gin.Context) {
+	var user User
+	user.Name = c.PostForm("name")
+	user.Password = c.PostForm("password")
+	token := c.DefaultPostForm("token", c.Query("token"))
+	if token == "" || user.Name == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "参数不完整",
+		})
+		return
+	}
+	_, err := AcceptInvitation(token, &user, auditEntry(c, AuditUserCreate, 0))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    fmt.Sprintf("创建新的用户成功 用户id为:%d", user.Id),
+	})
+}
+
+// Pendingregistrations 当前组织等待审核的注册申请
+func Pendingregistrations(c *gin.Context) {
+	users, err := ListPendingUsers(claimsTenant(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   NewUserViews(users),
+	})
+}
+
+// Approveregistration 通过注册申请
+func Approveregistration(c *gin.Context) {
+	reviewRegistration(c, UserActive)
+}
+
+// Rejectregistration 拒绝注册申请
+func Rejectregistration(c *gin.Context) {
+	reviewRegistration(c, UserRejected)
+}
+
+func reviewRegistration(c *gin.Context, status string) {
+	tenant := claimsTenant(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	after, err := SetUserStatus(tenant, id, status, auditEntry(c, AuditUserUpdate, id))
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "申请不存在或已处理"))
+		return
+	}
+	if after.GetEmail() != "" {
+		body := "你的注册申请已通过，现在可以登录了。"
+		if status == UserRejected {
+			body = "很抱歉，你的注册申请未通过。"
+		}
+		if err := mailer.Send(after.GetEmail(), "注册审核结果", body); err != nil {
+			log.Println("send review mail error", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "已处理",
+	})
+}
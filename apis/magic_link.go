@@ -0,0 +1,149 @@
+package apis
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+)
+
+// MagicLinkBase 免密登录邮件里链接的前缀
+var MagicLinkBase = "http://127.0.0.1:8000/login/magic"
+
+// 申请链接的浏览器会拿到这个cookie，点链接时用来判断是不是同一个浏览器
+const magicDeviceCookie = "magic_device"
+
+// signMagicToken 给令牌加签名，链接被篡改时不用查库直接拒绝
+func signMagicToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(jwt.GetSignKey()))
+	mac.Write([]byte("magic-link:" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func checkMagicToken(c *gin.Context) (string, bool) {
+	token := c.DefaultPostForm("token", c.Query("token"))
+	sig := c.DefaultPostForm("sig", c.Query("sig"))
+	if token == "" || !hmac.Equal([]byte(sig), []byte(signMagicToken(token))) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "链接无效或已过期",
+		})
+		return "", false
+	}
+	return token, true
+}
+
+// Requestmagiclink 申请免密登录链接，发到账号绑定的邮箱
+// 返回一个确认码给申请的浏览器显示，在其它设备打开链接时要输入它；账号不存在时也返回，不暴露账号是否存在
+func Requestmagiclink(c *gin.Context) {
+	account := c.PostForm("account")
+	device, err := c.Cookie(magicDeviceCookie)
+	if err != nil || device == "" {
+		if device, err = NewRandomToken(); err != nil {
+			c.Error(err)
+			return
+		}
+		c.SetCookie(magicDeviceCookie, device, int(MagicLinkTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+	}
+	code, err := NewConfirmCode()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	user, err := FindByAccount(tenant, account)
+	if err == nil && user.GetEmail() != "" {
+		// 申请太频繁时也不报错，否则能从429看出账号存在
+		token, err := user.CreateMagicLink(device, code)
+		if err != nil {
+			log.Println("create magic link error", err)
+		} else {
+			body := fmt.Sprintf("点击下面的链接直接登录，%d分钟内有效，只能使用一次：\n%s?token=%s&sig=%s",
+				int(MagicLinkTTL.Minutes()), MagicLinkBase, token, signMagicToken(token))
+			if err := mailer.Send(user.GetEmail(), "登录链接", body); err != nil {
+				log.Println("send magic link error", err)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "如果账号存在，登录链接已发送到绑定的邮箱；在其它设备打开链接时需要输入确认码",
+		"data":   gin.H{"confirm_code": code},
+	})
+}
+
+// Magiclogin 打开邮件里的链接
+// 在申请的那个浏览器里打开直接登录，换了浏览器要再调用 Confirmmagiclogin 确认
+func Magiclogin(c *gin.Context) {
+	token, ok := checkMagicToken(c)
+	if !ok {
+		return
+	}
+	link, err := FindMagicLink(token)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "链接无效或已过期"))
+		return
+	}
+	device, _ := c.Cookie(magicDeviceCookie)
+	if !link.SameDevice(device) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  1,
+			"msg":     "链接不是在申请的浏览器中打开的，请输入申请时显示的确认码",
+			"confirm": "/login/magic/confirm",
+		})
+		return
+	}
+	user, err := ConsumeMagicLink(token, auditEntry(c, AuditUserUpdate, 0))
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "链接无效或已过期"))
+		return
+	}
+	magicLogin(c, user)
+}
+
+// Confirmmagiclogin 在其它浏览器打开链接时，输入申请的浏览器上显示的确认码后登录
+// 只拿到链接的人没有确认码，确认码错误时链接作废
+func Confirmmagiclogin(c *gin.Context) {
+	token, ok := checkMagicToken(c)
+	if !ok {
+		return
+	}
+	code := c.PostForm("code")
+	if code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "请输入申请时显示的确认码",
+		})
+		return
+	}
+	user, err := ConfirmMagicLink(token, code, auditEntry(c, AuditUserUpdate, 0))
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "确认码错误或链接已失效，请重新申请"))
+		return
+	}
+	magicLogin(c, user)
+}
+
+func magicLogin(c *gin.Context, user User) {
+	c.SetCookie(magicDeviceCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	if blocked := user.LoginBlocked(); blocked != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    blocked,
+		})
+		return
+	}
+	recordLoginSuccess(c, user)
+	GenerateToken(c, user) //和密码登录签发同样的token
+}
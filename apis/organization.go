@@ -0,0 +1,109 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+)
+
+// requestTenant 未登录的接口用 tenant 参数（组织的slug）指定租户，不填是默认组织
+func requestTenant(c *gin.Context) (int, bool) {
+	org, err := FindOrganization(c.DefaultPostForm("tenant", c.Query("tenant")))
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "组织不存在"))
+		return 0, false
+	}
+	return org.Id, true
+}
+
+// claimsTenant 已登录的接口从token里取租户
+func claimsTenant(c *gin.Context) int {
+	return c.MustGet("claims").(*jwt.CustomClaims).Tenant
+}
+
+// Createorganization 新建组织，创建人自动成为该组织的管理员
+func Createorganization(c *gin.Context) {
+	org := Organization{Name: c.PostForm("name"), Slug: c.PostForm("slug")}
+	if org.Name == "" || org.Slug == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "参数不完整",
+		})
+		return
+	}
+	if err := org.CreateOrganization(); err != nil {
+		c.Error(errhandler.Wrap(err, "组织已存在"))
+		return
+	}
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	if _, err := AddMember(org.Id, claims.ID, RoleAdmin); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   org,
+	})
+}
+
+// Addmember 管理员修改当前组织里成员的角色，或者把收到本组织邀请的用户加入进来
+func Addmember(c *gin.Context) {
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	if orgId != claimsTenant(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status": -1,
+			"msg":    "只能管理当前所在的组织",
+		})
+		return
+	}
+	userId, _ := strconv.Atoi(c.PostForm("user_id"))
+	role, err := strconv.Atoi(c.DefaultPostForm("role", "0"))
+	if _, ok := RoleNames[role]; err != nil || !ok {
+		c.Error(errhandler.New(http.StatusUnprocessableEntity, "角色不存在"))
+		return
+	}
+	m, err := SetMemberRole(orgId, userId, role, auditEntry(c, AuditMemberRole, userId))
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   m,
+	})
+}
+
+// Myorganizations 当前用户加入的组织
+func Myorganizations(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	list, err := ListMemberships(claims.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   list,
+	})
+}
+
+// Switchorganization 切换到另一个加入的组织，重新签发带该租户的token
+func Switchorganization(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	if _, err := MemberRole(orgId, claims.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	user, err := GetUser(claims.ID)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
+		return
+	}
+	user.TenantId = orgId //只影响签发的token，不修改用户所属的组织
+	GenerateToken(c, user)
+}
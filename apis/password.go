@@ -0,0 +1,112 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/errhandler"
+	. "github.com/xdtest/project/models"
+	"github.com/xdtest/project/password"
+)
+
+// ResetLinkBase 重置密码邮件里链接的前缀
+var ResetLinkBase = "http://127.0.0.1:8000/password/reset"
+
+// passwordErrorMsg 密码不满足策略或者和历史密码重复时，返回给用户看的提示
+func passwordErrorMsg(err error) (string, bool) {
+	var perr *password.PolicyError
+	if errors.As(err, &perr) {
+		return perr.Error(), true
+	}
+	if errors.Is(err, ErrPasswordReused) {
+		return fmt.Sprintf("不能使用最近%d次用过的密码", password.Default.HistorySize), true
+	}
+	return "", false
+}
+
+// Forgotpassword 申请重置密码，按用户名或邮箱找到账号后发一封带一次性链接的邮件
+// 不管账号是否存在都返回同样的结果，避免被用来探测用户
+func Forgotpassword(c *gin.Context) {
+	account := c.PostForm("account")
+	if account == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "请填写用户名或邮箱",
+		})
+		return
+	}
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	user, err := FindByAccount(tenant, account)
+	if err == nil && user.GetEmail() != "" {
+		// 申请太频繁时也不报错，否则能从429看出账号存在
+		if err := sendResetMail(user); err != nil {
+			log.Println("send reset mail error", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "如果账号存在，重置链接已发送到绑定的邮箱",
+	})
+}
+
+// sendResetMail 生成重置令牌并发邮件
+func sendResetMail(user User) error {
+	token, err := user.CreatePasswordReset()
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("点击下面的链接重置密码，%d分钟内有效，只能使用一次：\n%s?token=%s",
+		int(ResetTokenTTL.Minutes()), ResetLinkBase, token)
+	return mailer.Send(user.GetEmail(), "重置密码", body)
+}
+
+// Sendpasswordreset 客服等有权限的人帮用户发送重置密码邮件，是否允许由策略文件决定
+func Sendpasswordreset(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, err := GetTenantUser(claimsTenant(c), id)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在或没有绑定邮箱"))
+		return
+	}
+	if user.GetEmail() == "" {
+		c.Error(errhandler.New(http.StatusUnprocessableEntity, "用户没有绑定邮箱"))
+		return
+	}
+	if err := sendResetMail(user); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "重置链接已发送到用户邮箱",
+	})
+}
+
+// Resetpassword 用邮件里的令牌设置新密码
+func Resetpassword(c *gin.Context) {
+	token := c.DefaultPostForm("token", c.Query("token"))
+	password := c.PostForm("password")
+	if token == "" || password == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": -1,
+			"msg":    "参数不完整",
+		})
+		return
+	}
+	if _, err := ResetPassword(token, password, auditEntry(c, AuditUserPasswordReset, 0)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "密码已重置，所有设备已退出登录，请用新密码登录",
+	})
+}
@@ -0,0 +1,89 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+	"github.com/xdtest/project/security"
+)
+
+// 长期有效的设备标识，用来判断是不是从没见过的设备登录
+const deviceCookie = "device_id"
+
+// deviceHash 当前请求的设备标识，没有cookie时退回用User-Agent
+func deviceHash(c *gin.Context) string {
+	if id, err := c.Cookie(deviceCookie); err == nil && id != "" {
+		return HashToken(id)
+	}
+	return HashToken(c.Request.UserAgent())
+}
+
+// ensureDeviceCookie 登录成功时给浏览器下发设备标识，返回新下发的标识，已经有cookie时返回空字符串
+func ensureDeviceCookie(c *gin.Context) string {
+	if id, err := c.Cookie(deviceCookie); err == nil && id != "" {
+		return ""
+	}
+	id, err := NewRandomToken()
+	if err != nil {
+		return ""
+	}
+	c.SetCookie(deviceCookie, id, int((365 * 24 * time.Hour).Seconds()), "/", "", c.Request.TLS != nil, true)
+	return id
+}
+
+// securityClient 当前请求的客户端，记录安全事件用
+func securityClient(c *gin.Context) security.Client {
+	return security.Client{IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), Device: deviceHash(c)}
+}
+
+// recordSecurityEvent 记录一条安全事件，失败只打日志不影响请求
+func recordSecurityEvent(c *gin.Context, userId int, account, typ string) {
+	security.RecordEvent(securityClient(c), userId, account, typ)
+}
+
+// recordLoginSuccess 记录登录成功，从没见过的设备额外记一条并发邮件提醒
+// 第一次登录时还没有cookie，按 User-Agent 判断后再下发cookie，登录成功按新的cookie记：
+// 下次带着cookie能认出是同一个设备，不保存cookie的客户端按 User-Agent 也能认出来
+func recordLoginSuccess(c *gin.Context, user User) {
+	client := securityClient(c)
+	issued := ""
+	if id := ensureDeviceCookie(c); id != "" {
+		issued = HashToken(id)
+	}
+	security.RecordLoginSuccess(client, user, issued)
+}
+
+func listSecurityEvents(c *gin.Context, userId int) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	events, err := ListSecurityEvents(userId, limit, offset)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   events,
+	})
+}
+
+// Mysecurityevents 当前用户自己的登录记录和安全事件
+func Mysecurityevents(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	listSecurityEvents(c, claims.ID)
+}
+
+// Usersecurityevents 管理员查看当前组织里某个用户的安全事件
+func Usersecurityevents(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := GetTenantUser(claimsTenant(c), id); err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
+		return
+	}
+	listSecurityEvents(c, id)
+}
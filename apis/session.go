@@ -0,0 +1,67 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+)
+
+// SessionView 返回给前端的会话，标记出当前这个
+type SessionView struct {
+	Session
+	Current bool `json:"current"`
+}
+
+// Listsessions 当前用户所有登录中的设备
+func Listsessions(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	sessions, err := ListSessions(claims.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	current, _ := c.Get("session")
+	views := make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, SessionView{
+			Session: s,
+			Current: current != nil && current.(Session).Id == s.Id,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   views,
+	})
+}
+
+// Revokesession 退出某个设备
+func Revokesession(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := RevokeSession(claims.ID, id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "已退出该设备",
+	})
+}
+
+// Revokeothersessions 退出除当前设备以外的所有设备
+func Revokeothersessions(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	count, err := RevokeOtherSessions(claims.ID, claims.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "已退出其它设备",
+		"data":   count,
+	})
+}
@@ -0,0 +1,167 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+	"github.com/xdtest/project/stream"
+)
+
+// SSE 相关的配置
+var (
+	HeartbeatInterval = 15 * time.Second // 没有事件时多久发一次注释，防止代理断开空闲连接
+	EventRetry        = 3000             // 建议客户端断线后多少毫秒重连
+)
+
+// 从事件表补读时每次读的条数
+const replayBatch = 200
+
+// Streamuserevents GET /v1/events/users 推送当前组织的用户新建、修改、删除、角色变化
+// 带 Last-Event-ID 头（或 last_event_id 参数）时先补发之后的事件；事件已经被清理时先发一个 reset 事件
+// 可以用 types（逗号分隔）和 user_id 参数只接收部分事件
+func Streamuserevents(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	filter := stream.Filter{TenantId: claims.Tenant}
+	filter.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	if types := c.Query("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			if !validUserEventType(t) {
+				c.Error(errhandler.New(http.StatusBadRequest, "不支持的事件类型 "+t))
+				return
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("last_event_id")
+	}
+	resume, err := strconv.Atoi(lastEventId)
+	if lastEventId != "" && (err != nil || resume < 0) {
+		c.Error(errhandler.New(http.StatusBadRequest, "Last-Event-ID 无效"))
+		return
+	}
+
+	sub, err := stream.Default.Subscribe(filter)
+	if err != nil {
+		c.Error(errhandler.New(http.StatusServiceUnavailable, "连接数太多，请稍后重试"))
+		return
+	}
+	defer stream.Default.Unsubscribe(sub)
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") //nginx 不要缓冲
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", EventRetry)
+	c.Writer.Flush()
+
+	s := &eventStream{c: c, filter: filter}
+	if lastEventId == "" {
+		if _, s.lastId, err = UserEventRange(); err != nil {
+			return
+		}
+	} else {
+		s.lastId = resume
+		if !s.catchUp() {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if sub.Lagged() && !s.catchUp() {
+				return
+			}
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case e := <-sub.C:
+			if sub.Lagged() && !s.catchUp() { //C 里少了事件，先从事件表补上
+				return
+			}
+			if e.Id > s.lastId && !s.send(e) {
+				return
+			}
+		}
+	}
+}
+
+// eventStream 一个 SSE 连接，lastId 是已经处理到的事件id，比它小的事件不再发送
+type eventStream struct {
+	c      *gin.Context
+	filter stream.Filter
+	lastId int
+}
+
+// catchUp 从事件表补发 lastId 之后的事件，断开连接时返回 false
+// lastId 之后的事件已经被清理了，先发 reset 告诉客户端重新拉取全量数据
+func (s *eventStream) catchUp() bool {
+	oldest, latest, err := UserEventRange()
+	if err != nil {
+		return false
+	}
+	if s.lastId+1 < oldest || s.lastId > latest {
+		sse.Encode(s.c.Writer, sse.Event{
+			Id:    strconv.Itoa(latest),
+			Event: "reset",
+			Data:  gin.H{"msg": "断线太久，部分事件已经被清理，请重新拉取数据"},
+		})
+		s.c.Writer.Flush()
+		s.lastId = latest
+		return true
+	}
+	for {
+		events, err := ListUserEvents(s.filter.Query(s.lastId, replayBatch))
+		if err != nil {
+			return false
+		}
+		for _, e := range events {
+			if !s.send(e) {
+				return false
+			}
+		}
+		if len(events) < replayBatch {
+			if latest > s.lastId { //latest 之前不符合过滤条件的事件也算处理过了
+				s.lastId = latest
+			}
+			return true
+		}
+	}
+}
+
+// send 写一个事件，客户端已经断开时返回 false
+func (s *eventStream) send(e UserEvent) bool {
+	err := sse.Encode(s.c.Writer, sse.Event{
+		Id:    strconv.Itoa(e.Id),
+		Event: e.Type,
+		Data:  e.Message(),
+	})
+	if err != nil {
+		return false
+	}
+	s.c.Writer.Flush()
+	s.lastId = e.Id
+	return s.c.Request.Context().Err() == nil
+}
+
+func validUserEventType(t string) bool {
+	for _, typ := range UserEventTypes {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
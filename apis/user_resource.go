@@ -0,0 +1,335 @@
+package apis
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/negotiate"
+	. "github.com/xdtest/project/models"
+	"github.com/xdtest/project/pb"
+)
+
+// UserView 对外返回的用户，不包含密码
+type UserView struct {
+	Id              int        `json:"id"`
+	Name            string     `json:"name"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            int        `json:"role"`
+	TenantId        int        `json:"tenant_id"`
+	Status          string     `json:"status"`
+	Version         int        `json:"version"`
+}
+
+// NewUserView 把用户转成对外的结构
+func NewUserView(u User) UserView {
+	return UserView{
+		Id:              u.Id,
+		Name:            u.Name,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		Role:            u.Role,
+		TenantId:        u.TenantId,
+		Status:          u.Status,
+		Version:         u.Version,
+	}
+}
+
+// NewUserViews 把用户列表转成对外的结构
+func NewUserViews(users []User) []UserView {
+	views := make([]UserView, 0, len(users))
+	for _, user := range users {
+		views = append(views, NewUserView(user))
+	}
+	return views
+}
+
+// userFields 允许通过 PUT/PATCH 修改的字段
+var userFields = map[string]bool{"name": true, "password": true, "email": true}
+
+// ownerTenant 修改、删除用户时按哪个组织过滤：自己的账号按注册时所在的组织，切换组织后也能改自己的资料；
+// 别人的账号只能是当前组织里注册的，从别的组织加入进来的成员只能查看
+func ownerTenant(c *gin.Context, id int) int {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	if id == claims.ID {
+		if user, err := GetUser(id); err == nil {
+			return user.TenantId
+		}
+	}
+	return claims.Tenant
+}
+
+// updateUser 修改用户并写审计日志，新旧接口共用；version 为0时不检查版本
+func updateUser(c *gin.Context, id, version int, fields map[string]interface{}) (User, error) {
+	u := User{TenantId: ownerTenant(c, id)}
+	before, _ := GetTenantUser(u.TenantId, id) //只用来判断邮箱有没有换
+	after, err := u.Updatefields(id, version, fields, auditEntry(c, AuditUserUpdate, id))
+	if err != nil {
+		return User{}, err
+	}
+	if after.Email != nil && after.GetEmail() != before.GetEmail() { //换了邮箱重新发验证邮件
+		if err := SendVerificationMail(after); err != nil {
+			log.Println("send verification mail error", err)
+		}
+	}
+	return after, nil
+}
+
+// createUser 在当前组织新建用户，写审计日志、发验证邮件，REST 和 GraphQL 共用
+func createUser(c *gin.Context, fields map[string]interface{}) (User, error) {
+	var user User
+	user.Name, _ = fields["name"].(string)
+	user.Password, _ = fields["password"].(string)
+	email, _ := fields["email"].(string)
+	user.SetEmail(email)
+	user.TenantId = claimsTenant(c)
+	user.Status = UserActive
+	if user.Name == "" || user.Password == "" {
+		return User{}, errhandler.New(http.StatusUnprocessableEntity, "用户名和密码不能为空")
+	}
+	id, err := user.Adduser(auditEntry(c, AuditUserCreate, 0))
+	if err != nil {
+		if errors.Is(err, errs.ErrConflict) {
+			msg := "用户名已存在"
+			if strings.Contains(errs.Constraint(err), "email") {
+				msg = "邮箱已被使用"
+			}
+			err = errhandler.Wrap(err, msg)
+		}
+		return User{}, err
+	}
+	user.Id = id
+	if user.Email != nil {
+		if err := SendVerificationMail(user); err != nil {
+			log.Println("send verification mail error", err)
+		}
+	}
+	return user, nil
+}
+
+// deleteUser 删除用户并写审计日志，新旧接口共用；version 为0时不检查版本
+func deleteUser(c *gin.Context, id, version int) (User, error) {
+	u := User{Id: id, TenantId: ownerTenant(c, id)}
+	user, err := u.Deleteuser(id, version, auditEntry(c, AuditUserDelete, id))
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// bindUserFields 按 Content-Type 读取请求体，检查字段名和类型
+// 只有 email 可以为 null，表示清空；Protobuf 的请求体是 pb.UserInput
+func bindUserFields(c *gin.Context) (map[string]interface{}, error) {
+	var body map[string]interface{}
+	switch negotiate.ContentFormat(c) {
+	case "":
+		return nil, negotiate.ErrUnsupportedMediaType
+	case negotiate.Protobuf:
+		var in pb.UserInput
+		if err := negotiate.Decode(c, &in); err != nil {
+			return nil, decodeError(err, "请求体不是有效的 UserInput")
+		}
+		body = in.Fields()
+	default:
+		if err := negotiate.Decode(c, &body); err != nil {
+			return nil, decodeError(err, "请求体必须是对象")
+		}
+		if body == nil {
+			return nil, errhandler.New(http.StatusBadRequest, "请求体必须是对象")
+		}
+	}
+	for k, v := range body {
+		if !userFields[k] {
+			return nil, errhandler.New(http.StatusUnprocessableEntity, "不支持修改字段 "+k)
+		}
+		if _, ok := v.(string); ok {
+			continue
+		}
+		if v == nil && k == "email" {
+			continue
+		}
+		return nil, errhandler.New(http.StatusUnprocessableEntity, "字段 "+k+" 必须是字符串")
+	}
+	if name, ok := body["name"]; ok && name == "" {
+		return nil, errhandler.New(http.StatusUnprocessableEntity, "用户名不能为空")
+	}
+	return body, nil
+}
+
+// notFound 记录不存在时换成具体的提示，其它错误原样返回
+func notFound(err error, msg string) error {
+	if errors.Is(err, errs.ErrNotFound) {
+		return errhandler.Wrap(err, msg)
+	}
+	return err
+}
+
+func paramID(c *gin.Context) int {
+	id, _ := strconv.Atoi(c.Param("id"))
+	return id
+}
+
+// currentUser 取租户内的用户，给 ifMatchVersion 比较多个ETag时用
+func currentUser(c *gin.Context, id int) func() (User, error) {
+	return func() (User, error) {
+		return GetTenantUser(claimsTenant(c), id)
+	}
+}
+
+// Indexusers GET /v1/users 当前组织的用户列表
+func Indexusers(c *gin.Context) {
+	u := User{TenantId: claimsTenant(c)}
+	users, err := u.Listusers()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"status": 0,
+		"data":   NewUserViews(users),
+	}, pb.NewUserList(users))
+}
+
+// Showuser GET /v1/users/:id
+func Showuser(c *gin.Context) {
+	user, err := GetTenantUser(claimsTenant(c), paramID(c))
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
+		return
+	}
+	if notModified(c, user) {
+		return
+	}
+	renderUser(c, http.StatusOK, user)
+}
+
+// Storeuser POST /v1/users 管理员在当前组织新建用户
+func Storeuser(c *gin.Context) {
+	fields, err := bindUserFields(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	user, err := createUser(c, fields)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", "/v1/users/"+strconv.Itoa(user.Id))
+	renderUser(c, http.StatusCreated, user)
+}
+
+// Replaceuser PUT /v1/users/:id 整体替换用户资料
+// 必须带上用户名，没带邮箱表示清空；密码不会返回给客户端，所以不带时保持不变
+func Replaceuser(c *gin.Context) {
+	id := paramID(c)
+	version, err := ifMatchVersion(c, currentUser(c, id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	fields, err := bindUserFields(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if _, ok := fields["name"]; !ok {
+		c.Error(errhandler.New(http.StatusUnprocessableEntity, "用户名不能为空"))
+		return
+	}
+	if _, ok := fields["email"]; !ok {
+		fields["email"] = nil
+	}
+	writeUpdated(c, id, version, fields)
+}
+
+// Patchuser PATCH /v1/users/:id 按 JSON merge patch (RFC 7396) 修改用户
+func Patchuser(c *gin.Context) {
+	patchUser(c, paramID(c))
+}
+
+// Destroyuser DELETE /v1/users/:id
+func Destroyuser(c *gin.Context) {
+	id := paramID(c)
+	version, err := ifMatchVersion(c, currentUser(c, id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := deleteUser(c, id, version); err != nil {
+		c.Error(notFound(err, "用户不存在"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Showme GET /v1/me 当前登录的用户
+func Showme(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	user, err := GetTenantUser(claims.Tenant, claims.ID)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
+		return
+	}
+	if notModified(c, user) {
+		return
+	}
+	renderUser(c, http.StatusOK, user)
+}
+
+// Patchme PATCH /v1/me 修改自己的资料
+func Patchme(c *gin.Context) {
+	patchUser(c, c.MustGet("claims").(*jwt.CustomClaims).ID)
+}
+
+func patchUser(c *gin.Context, id int) {
+	if negotiate.ContentFormat(c) == "" {
+		c.Error(negotiate.ErrUnsupportedMediaType)
+		return
+	}
+	version, err := ifMatchVersion(c, currentUser(c, id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	fields, err := bindUserFields(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	writeUpdated(c, id, version, fields)
+}
+
+func writeUpdated(c *gin.Context, id, version int, fields map[string]interface{}) {
+	user, err := updateUser(c, id, version, fields)
+	if err != nil {
+		c.Error(notFound(err, "用户不存在"))
+		return
+	}
+	c.Header("ETag", userETag(user))
+	renderUser(c, http.StatusOK, user)
+}
+
+// renderUser 按协商好的格式返回一个用户
+func renderUser(c *gin.Context, status int, user User) {
+	negotiate.Render(c, status, gin.H{
+		"status": 0,
+		"data":   NewUserView(user),
+	}, pb.NewUser(user))
+}
+
+// UserInput POST/PUT/PATCH /v1/users 的请求体，只用来生成接口文档
+// 实际按字段是否出现来处理，见 bindUserFields
+type UserInput struct {
+	Name     string  `json:"name"`
+	Password string  `json:"password"`
+	Email    *string `json:"email"`
+}
@@ -1,100 +1,213 @@
 package apis
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	jwtgo "github.com/dgrijalva/jwt-go" //需要安装 然后调用这个jwt-go包
 	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/errhandler"
 	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/negotiate"
 	. "github.com/xdtest/project/models"
+	"github.com/xdtest/project/pb"
+	"github.com/xdtest/project/security"
 )
 
+// Getuserslist 旧接口，已被 GET /v1/users 替代
 func Getuserslist(c *gin.Context) {
-	var user User
+	user := User{TenantId: claimsTenant(c)}
 	users, err := user.Listusers()
 	if err != nil {
-		log.Fatalln(err)
+		c.Error(err)
+		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"msg": users,
-	})
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"msg": NewUserViews(users),
+	}, pb.NewUserList(users))
 }
 
 func Addnewuser(c *gin.Context) {
+	switch RegistrationMode {
+	case RegistrationClosed:
+		registerReply(c, -1, "暂不开放注册")
+		return
+	case RegistrationInviteOnly:
+		registerReply(c, -1, "仅限受邀用户注册")
+		return
+	}
 	var user User
-	name := c.Request.FormValue("name")
-	password := c.Request.FormValue("password")
-	user.Name = name
-	user.Password = password
-	id, err := user.Adduser()
+	if err := bindRegister(c, &user); err != nil {
+		c.Error(decodeError(err, "请求体格式不正确"))
+		return
+	}
+	tenant, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	user.TenantId = tenant
+	if RegistrationMode == RegistrationApproval {
+		user.Status = UserPending //审核通过前不能登录
+	}
+	if user.Email == nil && EmailPolicy != EmailPolicyNone {
+		registerReply(c, -1, "请填写邮箱")
+		return
+	}
+	start := time.Now()
+	id, err := user.Adduser(auditEntry(c, AuditUserCreate, 0))
 	if err != nil {
-		if err.Error()[:10] == "Error 1062" {
-			fmt.Println("ssssss")
-			c.JSON(http.StatusOK, gin.H{
-				"msg": "用户名已存在",
-			})
+		if msg, ok := passwordErrorMsg(err); ok {
+			registerReply(c, -1, msg)
+		} else if errors.Is(err, errs.ErrConflict) {
+			status, msg := -1, "用户名已存在"
+			if strings.Contains(errs.Constraint(err), "email") {
+				msg = "邮箱已被使用"
+			}
+			if !VerboseAuthErrors {
+				status = 0
+				// 不告诉请求方是哪个重复了，有邮箱时把原因发到邮箱，只有邮箱主人能看到
+				msg = msgRegisterSubmitted
+				if user.Email != nil {
+					msg = msgRegisterQueued
+					body := "有人使用该邮箱申请注册，但用户名或邮箱已被使用。\n如果是你本人并且忘记了密码，请使用找回密码功能。"
+					if err := mailer.Send(user.GetEmail(), "注册结果", body); err != nil {
+						log.Println("send register notice error", err)
+					}
+				}
+				security.PadTiming(start)
+			}
+			registerReply(c, status, msg)
+		} else {
+			c.Error(err)
 		}
 
 	} else {
+		if user.Email != nil {
+			if err := SendVerificationMail(user); err != nil {
+				log.Println("send verification mail error", err)
+			}
+		}
 		msg := fmt.Sprintf("创建新的用户成功 用户id为:%d", id)
-		c.JSON(http.StatusOK, gin.H{
-			"msg": msg,
-		})
+		if user.Status == UserPending {
+			msg = fmt.Sprintf("注册申请已提交，等待管理员审核 用户id为:%d", id)
+		}
+		if !VerboseAuthErrors { //和重复注册时的响应保持一致，不返回用户id
+			msg = msgRegisterSubmitted
+			if user.Email != nil {
+				msg = msgRegisterQueued
+			}
+			security.PadTiming(start)
+		}
+		registerReply(c, 0, msg)
 	}
 
 }
 
+// bindRegister 读取注册请求，Protobuf 的请求体是 pb.UserInput，其它格式和表单字段一样
+func bindRegister(c *gin.Context, user *User) error {
+	switch negotiate.ContentFormat(c) {
+	case negotiate.Protobuf:
+		var in pb.UserInput
+		if err := negotiate.Decode(c, &in); err != nil {
+			return err
+		}
+		user.Name, user.Password = in.GetName().GetValue(), in.GetPassword().GetValue()
+		user.SetEmail(in.GetEmail().GetValue())
+	case negotiate.MsgPack, negotiate.YAML:
+		var req RegisterReq
+		if err := negotiate.Decode(c, &req); err != nil {
+			return err
+		}
+		user.Name, user.Password = req.Name, req.Password
+		user.SetEmail(req.Email)
+	default:
+		user.Name = c.Request.FormValue("name")
+		user.Password = c.Request.FormValue("password")
+		user.SetEmail(c.Request.FormValue("email"))
+	}
+	return nil
+}
+
+// registerReply 注册的响应只有提示，Protobuf 用 pb.Error，成功时 status 为0
+func registerReply(c *gin.Context, status int, msg string) {
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"msg": msg,
+	}, &pb.Error{Status: int32(status), Msg: msg})
+}
+
 type LoginReq struct {
-	Name     string `json:name`
-	Password string `json:password`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// RegisterReq MessagePack、YAML 格式的注册请求
+type RegisterReq struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
 }
 
 type LoginResult struct {
-	User  interface{}
+	User  UserView
 	Token string
 }
 
 // 生成令牌  创建jwt风格的token
 func GenerateToken(c *gin.Context, user User) {
-	j := &jwt.JWT{
-		[]byte("newtrekWang"),
+	issueToken(c, user, 3600, nil)
+}
+
+// issueToken 签发token，ttl为有效秒数；impersonator不为nil时是管理员在模拟登录该用户
+func issueToken(c *gin.Context, user User, ttl int64, impersonator *jwt.CustomClaims) {
+	role, err := MemberRole(user.TenantId, user.Id) //token里的角色是用户在当前组织里的角色
+	if errors.Is(err, ErrNotMember) {
+		role, err = user.Role, nil
 	}
-	claims := jwt.CustomClaims{
-		user.Id,
-		user.Name,
-		user.Password,
-		jwtgo.StandardClaims{
-			NotBefore: int64(time.Now().Unix() - 1000), // 签名生效时间
-			ExpiresAt: int64(time.Now().Unix() + 3600), // 过期时间 一小时
-			Issuer:    "newtrekWang",                   //签名的发行者
-		},
+	if err != nil {
+		c.Error(err)
+		return
 	}
+	deviceName := c.DefaultPostForm("device_name", c.GetHeader("X-Device-Name")) //每次登录记录成一个会话
+	if impersonator != nil {
+		deviceName = "impersonated by " + impersonator.Name
+	}
+	session, err := user.CreateSession(deviceName, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	claims := jwt.NewUserClaims(user, role, session.TokenId, ttl)
 
-	token, err := j.CreateToken(claims)
-
+	if impersonator != nil {
+		claims.ImpersonatorId = impersonator.ID
+		claims.ImpersonatorName = impersonator.Name
+	}
+	token, err := jwt.NewJWT().CreateToken(claims) //和 JWTAuth、gRPC 用同一个配置的密钥
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"status": -1,
-			"msg":    err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
-	log.Println(token)
+	if err := jwt.SetTokenCookie(c, token, int(ttl)); err != nil { //浏览器客户端走cookie
+		c.Error(err)
+		return
+	}
 
 	data := LoginResult{
-		User:  user,
+		User:  NewUserView(user),
 		Token: token,
 	}
-	c.JSON(http.StatusOK, gin.H{
+	negotiate.Render(c, http.StatusOK, gin.H{
 		"status": 0,
 		"msg":    "登录成功！",
 		"data":   data,
-	})
+	}, &pb.Token{User: pb.NewUser(user), Token: token})
 	return
 }
 
@@ -112,77 +225,165 @@ func GetDataByTime(c *gin.Context) {
 
 func Userlogin(c *gin.Context) {
 	var user User
-	if c.Bind(&user) == nil { //把form格式传过来的数据绑定到结构体user中去
+	bindErr := bindLogin(c, &user)
+	if bindErr == nil { //把form、JSON等格式传过来的数据绑定到结构体user中去
+		tenant, ok := requestTenant(c)
+		if !ok {
+			return
+		}
+		user.TenantId = tenant
+		start := time.Now()
 		msg, err := user.Login()
+		// 用户不存在时也查一次锁定状态，让两种失败走同样的数据库操作
+		locked, _ := IsLocked(msg.Id)
+		if locked && (err == nil || err == ErrWrongPassword) {
+			recordSecurityEvent(c, msg.Id, user.Name, EventAccountLocked)
+			loginFailed(c, start, "密码错误次数过多，账号已临时锁定")
+			return
+		}
 		if err != nil {
-			if err.Error() == "record not found" {
-				c.JSON(http.StatusOK, gin.H{
-					"msg":  "用户不存在",
-					"user": nil,
-				})
+			if errors.Is(err, errs.ErrNotFound) {
+				recordSecurityEvent(c, 0, user.Name, EventUnknownUser)
+				loginFailed(c, start, "用户不存在")
+			} else if err == ErrWrongPassword {
+				recordSecurityEvent(c, msg.Id, user.Name, EventWrongPassword)
+				loginFailed(c, start, "登陆错误")
 			} else {
-				c.JSON(http.StatusOK, gin.H{
-					"msg":  "登陆错误",
-					"user": nil,
-				})
-
+				c.Error(err)
 			}
 
+		} else if blocked := msg.LoginBlocked(); blocked != "" {
+			negotiate.Render(c, http.StatusOK, gin.H{
+				"msg":  blocked,
+				"user": nil,
+			}, &pb.Error{Status: -1, Msg: blocked})
 		} else {
+			recordLoginSuccess(c, msg)
 			GenerateToken(c, msg) //创建token
 			// c.JSON(http.StatusOK, gin.H{
 			// 	"msg":  "登陆成功",
 			// 	"user": msg,
 			// })
 		}
+	} else if errors.Is(bindErr, negotiate.ErrRequestTooLarge) {
+		c.Error(bindErr)
 	} else {
-		c.JSON(400, gin.H{"JSON=== status": "binding JSON error!"})
+		negotiate.Render(c, 400, gin.H{"JSON=== status": "binding JSON error!"}, &pb.Error{Status: -1, Msg: "binding error"})
 	}
 }
 
+// bindLogin 读取登录请求，Protobuf 的请求体是 pb.LoginRequest，表单和JSON交给 gin 绑定
+func bindLogin(c *gin.Context, user *User) error {
+	switch negotiate.ContentFormat(c) {
+	case negotiate.Protobuf:
+		var req pb.LoginRequest
+		if err := negotiate.Decode(c, &req); err != nil {
+			return err
+		}
+		user.Name, user.Password = req.Name, req.Password
+	case negotiate.MsgPack, negotiate.YAML:
+		var req LoginReq
+		if err := negotiate.Decode(c, &req); err != nil {
+			return err
+		}
+		user.Name, user.Password = req.Name, req.Password
+	default:
+		return c.Bind(user)
+	}
+	return nil
+}
+
+// loginFailed 登录失败的响应，非详细模式下统一提示并补齐耗时
+func loginFailed(c *gin.Context, start time.Time, verbose string) {
+	msg := msgLoginFailed
+	if VerboseAuthErrors {
+		msg = verbose
+	} else {
+		security.PadTiming(start)
+	}
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"msg":  msg,
+		"user": nil,
+	}, &pb.Error{Status: -1, Msg: msg})
+}
+
+// Deleteuser 旧接口 DELETE /deleteuser?id=，已被 DELETE /v1/users/:id 替代
 func Deleteuser(c *gin.Context) {
-	ids := c.Query("id")
-	id, _ := strconv.Atoi(ids)
-	var u User
-	u.Id = id
-	user, err := u.Deleteuser(id)
+	id, _ := strconv.Atoi(c.Query("id"))
+	user, err := deleteUser(c, id, parseETag(c.GetHeader("If-Match")))
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"msg":  "用户不存在",
-			"user": nil,
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"msg":  "删除陈工",
-			"user": user,
-		})
+		c.Error(notFound(err, "用户不存在"))
+		return
 	}
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"msg":  "删除陈工",
+		"user": NewUserView(user),
+	}, pb.NewUser(user))
 }
 
+// Updatauser 旧接口 POST /v1/updatauser?id=，已被 PATCH /v1/users/:id 替代
 func Updatauser(c *gin.Context) {
-	ids := c.Query("id")
-	name := c.DefaultPostForm("name", "")
-	password := c.DefaultPostForm("password", "")
+	id, _ := strconv.Atoi(c.Query("id"))
+	fields, err := bindLegacyUpdate(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	user, err := updateUser(c, id, parseETag(c.GetHeader("If-Match")), fields)
+	if err != nil { //密码不符合策略时是422，见 errors.go
+		c.Error(notFound(err, "用户不存在"))
+		return
+	}
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"code":    1,
+		"message": "修改成功",
+	}, pb.NewUser(user))
+}
 
-	id, _ := strconv.Atoi(ids)
-	var user User
+// bindLegacyUpdate 读取旧接口的修改请求，只能改用户名和密码，空值表示不修改
+// 和注册一样，Protobuf 的请求体是 pb.UserInput，其它格式和表单字段一样
+func bindLegacyUpdate(c *gin.Context) (map[string]interface{}, error) {
+	var name, password string
+	switch negotiate.ContentFormat(c) {
+	case negotiate.Protobuf:
+		var in pb.UserInput
+		if err := negotiate.Decode(c, &in); err != nil {
+			return nil, decodeError(err, "请求体不是有效的 UserInput")
+		}
+		name, password = in.GetName().GetValue(), in.GetPassword().GetValue()
+	case negotiate.MsgPack, negotiate.YAML:
+		var req LoginReq
+		if err := negotiate.Decode(c, &req); err != nil {
+			return nil, decodeError(err, "请求体格式不正确")
+		}
+		name, password = req.Name, req.Password
+	default:
+		name, password = c.PostForm("name"), c.PostForm("password")
+	}
+	fields := map[string]interface{}{}
 	if name != "" {
-		user.Name = name
+		fields["name"] = name
 	}
 	if password != "" {
-		user.Password = password
+		fields["password"] = password
 	}
-	result, err := user.Updatauser(id)
-	if err != nil || result.Id == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"code":    -1,
-			"message": "修改失败",
-		})
+	return fields, nil
+}
+
+// Getuserinfo 查看用户资料，只能看自己的，有权限的可以看别人的
+// 旧接口 GET /v1/userinfo?id=，已被 GET /v1/users/:id 替代
+func Getuserinfo(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Query("id"))
+	user, err := GetTenantUser(claimsTenant(c), id)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "用户不存在"))
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"code":    1,
-		"message": "修改成功",
-	})
-
+	if notModified(c, user) {
+		return
+	}
+	negotiate.Render(c, http.StatusOK, gin.H{
+		"msg":  "ok",
+		"user": NewUserView(user),
+	}, pb.NewUser(user))
 }
@@ -0,0 +1,171 @@
+package apis
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/errhandler"
+	"github.com/xdtest/project/middleware/jwt"
+	. "github.com/xdtest/project/models"
+	"github.com/xdtest/project/webhook"
+)
+
+// Createwebhook 管理员给当前组织添加 webhook 订阅，secret 只在这里返回一次
+func Createwebhook(c *gin.Context) {
+	claims := c.MustGet("claims").(*jwt.CustomClaims)
+	hook := Webhook{
+		TenantId:  claims.Tenant,
+		URL:       c.PostForm("url"),
+		Events:    c.PostForm("events"),
+		Secret:    c.PostForm("secret"),
+		Active:    true,
+		CreatedBy: claims.ID,
+	}
+	if msg := checkWebhook(hook); msg != "" {
+		c.Error(errhandler.New(http.StatusUnprocessableEntity, msg))
+		return
+	}
+	if err := hook.CreateWebhook(); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "请保存 secret，之后不会再显示",
+		"data":   hook,
+		"secret": hook.Secret,
+	})
+}
+
+// Listwebhooks 当前组织的 webhook 订阅
+func Listwebhooks(c *gin.Context) {
+	list, err := ListWebhooks(claimsTenant(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   list,
+	})
+}
+
+// Updatewebhook 修改订阅的 url、events、active、secret，没带的参数不修改
+func Updatewebhook(c *gin.Context) {
+	tenant := claimsTenant(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	hook, err := GetWebhook(tenant, id)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "webhook 不存在"))
+		return
+	}
+	fields := map[string]interface{}{}
+	if v, ok := c.GetPostForm("url"); ok {
+		hook.URL, fields["url"] = v, v
+	}
+	if v, ok := c.GetPostForm("events"); ok {
+		hook.Events, fields["events"] = v, v
+	}
+	if v, ok := c.GetPostForm("secret"); ok && v != "" {
+		fields["secret"] = v
+	}
+	if v, ok := c.GetPostForm("active"); ok {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			c.Error(errhandler.New(http.StatusUnprocessableEntity, "active 必须是 true 或 false"))
+			return
+		}
+		fields["active"] = active
+	}
+	if msg := checkWebhook(hook); msg != "" {
+		c.Error(errhandler.New(http.StatusUnprocessableEntity, msg))
+		return
+	}
+	if hook, err = UpdateWebhook(tenant, id, fields); err != nil {
+		c.Error(errhandler.Wrap(err, "webhook 不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   hook,
+	})
+}
+
+// Deletewebhook 删除订阅和它的投递记录，没发出去的投递不再发送
+func Deletewebhook(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := DeleteWebhook(claimsTenant(c), id); err != nil {
+		c.Error(errhandler.Wrap(err, "webhook 不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "已删除",
+	})
+}
+
+// Webhookdeliveries 订阅的投递记录，包括每次发送的状态码、错误和重试时间
+func Webhookdeliveries(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := GetWebhook(claimsTenant(c), id); err != nil {
+		c.Error(errhandler.Wrap(err, "webhook 不存在"))
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	list, err := ListDeliveries(id, limit, offset)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   list,
+	})
+}
+
+// Redeliverwebhook 用原来的内容重新发送一次，成功、失败或 dead 的投递都可以重发
+func Redeliverwebhook(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	deliveryId, _ := strconv.Atoi(c.Param("delivery"))
+	if _, err := GetWebhook(claimsTenant(c), id); err != nil {
+		c.Error(errhandler.Wrap(err, "webhook 不存在"))
+		return
+	}
+	d, err := Redeliver(id, deliveryId)
+	if err != nil {
+		c.Error(errhandler.Wrap(err, "投递记录不存在"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"msg":    "已加入发送队列",
+		"data":   d,
+	})
+}
+
+// checkWebhook 检查 url 和事件类型，有问题时返回提示
+func checkWebhook(hook Webhook) string {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "url 必须是 http 或 https 地址"
+	}
+	// 域名解析到内网的在发送时由 webhook 包挡住，这里先拒绝明显的
+	host := u.Hostname()
+	if ip := net.ParseIP(host); strings.EqualFold(host, "localhost") || ip != nil && webhook.PrivateIP(ip) {
+		return "url 不能是本机或内网地址"
+	}
+	if hook.Events == "" {
+		return ""
+	}
+	for _, t := range strings.Split(hook.Events, ",") {
+		if !validUserEventType(t) {
+			return "不支持的事件类型 " + t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package bulk
+
+import (
+	"io"
+
+	"github.com/xdtest/project/models"
+)
+
+// flushEvery 导出时每写这么多行推送一次
+const flushEvery = 500
+
+// Export 把租户内的用户逐行写到 w，不会一次把所有用户读进内存
+// 返回写出的行数；中途出错时已经写出的内容无法收回
+func Export(w io.Writer, format string, tenantId int) (n int, err error) {
+	writer, err := NewWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	err = models.EachUser(tenantId, func(u models.User) error {
+		role, ok := models.RoleNames[u.Role]
+		if !ok {
+			role = ""
+		}
+		if err := writer.Write(ExportRecord{
+			Id:              u.Id,
+			Name:            u.Name,
+			Email:           u.Email,
+			EmailVerifiedAt: u.EmailVerifiedAt,
+			Role:            role,
+			Status:          u.Status,
+			TenantId:        u.TenantId,
+			Version:         u.Version,
+		}); err != nil {
+			return err
+		}
+		n++
+		if n%flushEvery == 0 {
+			return writer.Flush()
+		}
+		return nil
+	})
+	if ferr := writer.Flush(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
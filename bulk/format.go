@@ -0,0 +1,269 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支持的文件格式
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+var ErrUnknownFormat = errors.New("unknown format, use csv or jsonl")
+
+// Format 按名字、文件扩展名或 Content-Type 判断格式，认不出时返回空字符串
+func Format(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+	switch {
+	case name == CSV, strings.HasSuffix(name, ".csv"), name == "text/csv":
+		return CSV
+	case name == JSONL, name == "ndjson", strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".ndjson"),
+		name == "application/jsonl", name == "application/x-ndjson", name == "application/x-jsonlines":
+		return JSONL
+	}
+	return ""
+}
+
+// ContentType 格式对应的 Content-Type
+func ContentType(format string) string {
+	if format == JSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Record 导入文件里的一行，都是原始字符串，由 Import 校验
+type Record struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`   // 角色名或数字，空表示不修改
+	Status   string `json:"status"` // active, pending, rejected，空表示默认
+}
+
+// RowError 某一行的错误，Line 是文件里的行号
+type RowError struct {
+	Line    int    `json:"line"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"error"`
+}
+
+func (e *RowError) Error() string {
+	return "line " + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+// Reader 逐行读取导入文件，读完返回 io.EOF
+// 某一行格式不对时返回 *RowError，可以继续读下一行
+type Reader interface {
+	Read() (line int, rec Record, err error)
+}
+
+// NewReader 按格式创建 Reader
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case JSONL:
+		return &jsonlReader{scanner: newScanner(r)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// csvReader 第一行是表头，列的顺序随意，多余的列忽略
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("csv header must contain a name column")
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) Read() (int, Record, error) {
+	fields, err := c.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return perr.StartLine, Record{}, &RowError{Line: perr.StartLine, Message: perr.Err.Error()}
+		}
+		return 0, Record{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+	get := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	return line, Record{
+		Name:     get("name"),
+		Password: get("password"),
+		Email:    get("email"),
+		Role:     get("role"),
+		Status:   get("status"),
+	}, nil
+}
+
+// jsonlReader 每行一个JSON对象，空行跳过
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return s
+}
+
+func (j *jsonlReader) Read() (int, Record, error) {
+	for j.scanner.Scan() {
+		j.line++
+		data := bytes.TrimSpace(j.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return j.line, Record{}, &RowError{Line: j.line, Message: "invalid json: " + err.Error()}
+		}
+		str := func(name string) string {
+			switch v := raw[name].(type) {
+			case string:
+				return strings.TrimSpace(v)
+			case float64: // role 可以写成数字
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			return ""
+		}
+		return j.line, Record{
+			Name:     str("name"),
+			Password: str("password"),
+			Email:    str("email"),
+			Role:     str("role"),
+			Status:   str("status"),
+		}, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return j.line, Record{}, err
+	}
+	return j.line, Record{}, io.EOF
+}
+
+// ExportRecord 导出的一行，不包含密码
+type ExportRecord struct {
+	Id              int        `json:"id"`
+	Name            string     `json:"name"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            string     `json:"role"`
+	Status          string     `json:"status"`
+	TenantId        int        `json:"tenant_id"`
+	Version         int        `json:"version"`
+}
+
+var exportHeader = []string{"id", "name", "email", "email_verified_at", "role", "status", "tenant_id", "version"}
+
+// Writer 逐行写导出文件
+type Writer interface {
+	Write(rec ExportRecord) error
+	Flush() error
+}
+
+// NewWriter 按格式创建 Writer，CSV 会先写表头
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw, out: w}, nil
+	case JSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw), out: w}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+type csvWriter struct {
+	w   *csv.Writer
+	out io.Writer
+}
+
+func (c *csvWriter) Write(rec ExportRecord) error {
+	verified := ""
+	if rec.EmailVerifiedAt != nil {
+		verified = rec.EmailVerifiedAt.Format(time.RFC3339)
+	}
+	email := ""
+	if rec.Email != nil {
+		email = *rec.Email
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(rec.Id), rec.Name, email, verified, rec.Role, rec.Status,
+		strconv.Itoa(rec.TenantId), strconv.Itoa(rec.Version),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return err
+	}
+	flush(c.out)
+	return nil
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	out io.Writer
+}
+
+func (j *jsonlWriter) Write(rec ExportRecord) error {
+	return j.enc.Encode(rec)
+}
+
+func (j *jsonlWriter) Flush() error {
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	flush(j.out)
+	return nil
+}
+
+// flush 输出是HTTP响应时把已经写的内容推给客户端
+func flush(w io.Writer) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
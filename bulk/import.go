@@ -0,0 +1,181 @@
+package bulk
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xdtest/project/models"
+	"github.com/xdtest/project/password"
+)
+
+// DefaultBatchSize 每个事务导入的行数
+const DefaultBatchSize = 100
+
+// Options 导入参数
+type Options struct {
+	TenantId  int
+	Mode      string // models.ImportSkip 或 models.ImportUpsert，默认跳过
+	DryRun    bool   // 只校验不写入，数据库里的唯一约束也会检查
+	BatchSize int
+	// Audit 审计日志的操作人、组织等，新建、更新的每一行按它写审计日志，和这一批在同一个事务里提交
+	// 为nil时不写；dry-run 时不会写
+	Audit *models.AuditLog
+}
+
+// Report 导入结果，Errors 按行号排列
+type Report struct {
+	DryRun  bool       `json:"dry_run"`
+	Total   int        `json:"total"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"`
+	Failed  int        `json:"failed"`
+	Errors  []RowError `json:"errors"`
+}
+
+// row 校验通过、等待写入的一行
+type row struct {
+	line int
+	user models.User
+}
+
+// Import 从 r 读取用户并分批导入，每批一个事务
+// 格式不对、校验不通过的行记到 Report.Errors 里，不影响其它行；
+// 某一批写数据库出错时这一批整体回滚，出错的行记具体错误，其它行记为随批回滚
+// 返回的 error 只表示文件本身读不下去
+func Import(r io.Reader, format string, opts Options) (*Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = models.ImportSkip
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	reader, err := NewReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{DryRun: opts.DryRun, Errors: []RowError{}}
+	seen := map[string]int{}
+	var batch []row
+	for {
+		line, rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if rowErr, ok := err.(*RowError); ok {
+			report.Total++
+			report.fail(*rowErr)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Total++
+		u, msg := validate(rec)
+		if msg == "" {
+			if first, ok := seen[u.Name]; ok {
+				msg = "duplicate name, first seen on line " + strconv.Itoa(first)
+			} else {
+				seen[u.Name] = line
+			}
+		}
+		if msg != "" {
+			report.fail(RowError{Line: line, Name: rec.Name, Message: msg})
+			continue
+		}
+		batch = append(batch, row{line: line, user: u})
+		if len(batch) >= opts.BatchSize {
+			report.flush(batch, opts)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		report.flush(batch, opts)
+	}
+	return report, nil
+}
+
+// flush 在一个事务里写入一批
+func (rep *Report) flush(batch []row, opts Options) {
+	users := make([]models.User, len(batch))
+	for i, r := range batch {
+		users[i] = r.user
+	}
+	results, failed, err := models.ImportUsers(opts.TenantId, users, opts.Mode, opts.DryRun, opts.Audit)
+	if err != nil {
+		for i, r := range batch {
+			msg := "batch rolled back"
+			if i == failed || failed < 0 {
+				msg = err.Error()
+			}
+			rep.fail(RowError{Line: r.line, Name: r.user.Name, Message: msg})
+		}
+		return
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			rep.fail(RowError{Line: batch[i].line, Name: batch[i].user.Name, Message: res.Err.Error()})
+			continue
+		}
+		switch res.Action {
+		case models.ImportCreated:
+			rep.Created++
+		case models.ImportUpdated:
+			rep.Updated++
+		case models.ImportSkipped:
+			rep.Skipped++
+		}
+	}
+}
+
+func (rep *Report) fail(e RowError) {
+	rep.Failed++
+	rep.Errors = append(rep.Errors, e)
+}
+
+// validate 检查一行的内容，返回要写入的用户；不通过时返回错误说明
+func validate(rec Record) (models.User, string) {
+	u := models.User{Name: rec.Name, Password: rec.Password, Role: models.ImportKeepRole, Status: rec.Status}
+	if u.Name == "" {
+		return u, "name is required"
+	}
+	if u.Password != "" {
+		if err := password.Default.Validate(u.Password, u.Name); err != nil {
+			return u, err.Error()
+		}
+	}
+	if rec.Email != "" {
+		u.SetEmail(rec.Email)
+		if !models.ValidEmail(u.GetEmail()) {
+			return u, "invalid email"
+		}
+	}
+	if rec.Role != "" {
+		role, ok := parseRole(rec.Role)
+		if !ok {
+			return u, "unknown role " + rec.Role
+		}
+		u.Role = role
+	}
+	switch u.Status {
+	case "", models.UserActive, models.UserPending, models.UserRejected:
+	default:
+		return u, "unknown status " + u.Status
+	}
+	return u, ""
+}
+
+// parseRole 角色可以写名字（user、admin、support）或数字
+func parseRole(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		_, ok := models.RoleNames[n]
+		return n, ok
+	}
+	for id, name := range models.RoleNames {
+		if strings.EqualFold(name, s) {
+			return id, true
+		}
+	}
+	return 0, false
+}
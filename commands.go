@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xdtest/project/bulk"
+	gorm "github.com/xdtest/project/database"
+	model "github.com/xdtest/project/models"
+)
+
+// runCommand 执行命令行子命令，返回进程退出码
+func runCommand(name string, args []string) int {
+	defer gorm.Eloquent.Close()
+	switch name {
+	case "audit-verify":
+		return auditVerify()
+	case "users-import":
+		return usersImport(args)
+	case "users-export":
+		return usersExport(args)
+	default:
+		fmt.Println("unknown command:", name)
+		fmt.Println("usage: project [audit-verify | users-import <file> | users-export]")
+		return 2
+	}
+}
+
+// auditVerify 校验审计日志hash链和最近的检查点，发现篡改或末尾被删时返回1
+func auditVerify() int {
+	badId, checked, err := model.VerifyAuditChain()
+	if err != nil {
+		fmt.Println("verify audit log error:", err)
+		return 1
+	}
+	if badId != 0 {
+		fmt.Printf("audit log tampered at id %d (checked %d entries)\n", badId, checked)
+		return 1
+	}
+	fmt.Printf("audit log ok (%d entries)\n", checked)
+	return 0
+}
+
+// usersImport 从CSV、JSON Lines文件导入用户，有行导入失败时返回1
+// project users-import [-format csv|jsonl] [-mode skip|upsert] [-dry-run] [-tenant slug] [-batch-size n] <file>
+func usersImport(args []string) int {
+	fs := flag.NewFlagSet("users-import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv or jsonl, detected from the file extension by default")
+	mode := fs.String("mode", model.ImportSkip, "what to do with existing names: skip or upsert")
+	dryRun := fs.Bool("dry-run", false, "validate only, write nothing")
+	tenant := fs.String("tenant", "", "organization slug, default organization if empty")
+	batchSize := fs.Int("batch-size", bulk.DefaultBatchSize, "rows per transaction")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*mode != model.ImportSkip && *mode != model.ImportUpsert) {
+		fmt.Println("usage: project users-import [-format csv|jsonl] [-mode skip|upsert] [-dry-run] [-tenant slug] [-batch-size n] <file>")
+		return 2
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = bulk.Format(path)
+	}
+	org, err := model.FindOrganization(*tenant)
+	if err != nil {
+		fmt.Println("find organization error:", err)
+		return 1
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Println("open file error:", err)
+		return 1
+	}
+	defer f.Close()
+	report, err := bulk.Import(f, *format, bulk.Options{
+		TenantId:  org.Id,
+		Mode:      *mode,
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+		Audit:     &model.AuditLog{TargetType: "user", TenantId: org.Id, ActorName: "cli"},
+	})
+	if err != nil {
+		fmt.Println("import error:", err)
+		return 1
+	}
+	for _, e := range report.Errors {
+		fmt.Printf("line %d %s: %s\n", e.Line, e.Name, e.Message)
+	}
+	prefix := ""
+	if report.DryRun {
+		prefix = "dry run: "
+	}
+	fmt.Printf("%s%d rows, %d created, %d updated, %d skipped, %d failed\n",
+		prefix, report.Total, report.Created, report.Updated, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// usersExport 导出用户到文件或标准输出
+// project users-export [-format csv|jsonl] [-tenant slug] [-o file]
+func usersExport(args []string) int {
+	fs := flag.NewFlagSet("users-export", flag.ContinueOnError)
+	format := fs.String("format", bulk.CSV, "csv or jsonl")
+	tenant := fs.String("tenant", "", "organization slug, default organization if empty")
+	out := fs.String("o", "", "output file, stdout if empty")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	org, err := model.FindOrganization(*tenant)
+	if err != nil {
+		fmt.Println("find organization error:", err)
+		return 1
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Println("create file error:", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := bulk.Export(w, bulk.Format(*format), org.Id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export error:", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %d users\n", n)
+	return 0
+}
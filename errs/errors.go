@@ -0,0 +1,94 @@
+package errs
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 领域错误，handler 用 errors.Is 判断，不再关心底层是哪种数据库
+var (
+	ErrNotFound    = errors.New("record not found")
+	ErrConflict    = errors.New("conflict")             // 唯一约束冲突
+	ErrConstraint  = errors.New("constraint violation") // 外键、非空、长度、check 等约束
+	ErrUnavailable = errors.New("database unavailable") // 连不上、锁等待超时、死锁等，可以重试
+)
+
+// Error 翻译后的错误，保留原始错误和违反的约束（索引名或字段）
+type Error struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + " (" + e.Constraint + "): " + e.Err.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Translator 把某种驱动的错误翻译成领域错误，不认识时返回nil
+type Translator func(err error) *Error
+
+var translators = []Translator{translateGorm, translateMySQL, translatePostgres, translateSQLite, translateConn}
+
+// Register 注册额外的翻译器，优先于内置的
+func Register(t Translator) {
+	translators = append([]Translator{t}, translators...)
+}
+
+// Translate 把数据库错误翻译成领域错误，已经翻译过的和不认识的原样返回
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	for _, t := range translators {
+		if e := t(err); e != nil {
+			return e
+		}
+	}
+	return err
+}
+
+// Constraint 返回违反的约束名，不是约束错误时返回空字符串
+func Constraint(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Constraint
+	}
+	return ""
+}
+
+func translateGorm(err error) *Error {
+	if gorm.IsRecordNotFoundError(err) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+	return nil
+}
+
+// translateConn 驱动无关的连接错误
+func translateConn(err error) *Error {
+	if errors.Is(err, driver.ErrBadConn) {
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	return nil
+}
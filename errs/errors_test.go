@@ -0,0 +1,131 @@
+package errs
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		kind       error // nil 表示不认识，原样返回
+		constraint string
+	}{
+		{"gorm not found", gorm.ErrRecordNotFound, ErrNotFound, ""},
+		{"bad conn", driver.ErrBadConn, ErrUnavailable, ""},
+		{"net error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrUnavailable, ""},
+
+		{"mysql duplicate", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-bob' for key 'uix_users_tenant_name'"}, ErrConflict, "uix_users_tenant_name"},
+		{"mysql 8 duplicate", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-bob@x.com' for key 'users.uix_users_tenant_email'"}, ErrConflict, "uix_users_tenant_email"},
+		{"mysql foreign key", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, ErrConstraint, ""},
+		{"mysql too long", &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name'"}, ErrConstraint, ""},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, ErrUnavailable, ""},
+		{"mysql invalid conn", mysql.ErrInvalidConn, ErrUnavailable, ""},
+		{"mysql syntax", &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, nil, ""},
+		{"wrapped mysql", fmt.Errorf("create user: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'uix_users_tenant_name'"}), ErrConflict, "uix_users_tenant_name"},
+
+		{"postgres unique", &pq.Error{Code: "23505", Constraint: "uix_users_tenant_email"}, ErrConflict, "uix_users_tenant_email"},
+		{"postgres foreign key", &pq.Error{Code: "23503", Constraint: "fk_memberships_user"}, ErrConstraint, "fk_memberships_user"},
+		{"postgres not null", &pq.Error{Code: "23502", Column: "name"}, ErrConstraint, ""},
+		{"postgres too long", &pq.Error{Code: "22001", Column: "name"}, ErrConstraint, "name"},
+		{"postgres connection", &pq.Error{Code: "08006"}, ErrUnavailable, ""},
+		{"postgres deadlock", &pq.Error{Code: "40P01"}, ErrUnavailable, ""},
+		{"postgres serialization", &pq.Error{Code: "40001"}, ErrUnavailable, ""},
+		{"postgres syntax", &pq.Error{Code: "42601"}, nil, ""},
+		{"wrapped postgres", fmt.Errorf("update: %w", &pq.Error{Code: "23505", Constraint: "uix_users_tenant_name"}), ErrConflict, "uix_users_tenant_name"},
+
+		{"sqlite unique", errors.New("UNIQUE constraint failed: users.tenant_id, users.name"), ErrConflict, "users.tenant_id, users.name"},
+		{"sqlite not null", errors.New("NOT NULL constraint failed: users.name"), ErrConstraint, ""},
+		{"sqlite foreign key", errors.New("FOREIGN KEY constraint failed"), ErrConstraint, ""},
+		{"sqlite check", errors.New("CHECK constraint failed: status"), ErrConstraint, ""},
+		{"sqlite locked", errors.New("database is locked"), ErrUnavailable, ""},
+		{"sqlite table locked", errors.New("database table is locked: users"), ErrUnavailable, ""},
+		{"sqlite prefix only", errors.New("error: UNIQUE constraint failed: users.name"), nil, ""},
+		{"sqlite no such table", errors.New("no such table: users"), nil, ""},
+	}
+	for _, tt := range tests {
+		got := Translate(tt.err)
+		if tt.kind == nil {
+			if got != tt.err {
+				t.Errorf("%s: Translate = %v, want the original error", tt.name, got)
+			}
+			continue
+		}
+		if !errors.Is(got, tt.kind) {
+			t.Errorf("%s: Translate = %v, want kind %v", tt.name, got, tt.kind)
+		}
+		if c := Constraint(got); c != tt.constraint {
+			t.Errorf("%s: Constraint = %q, want %q", tt.name, c, tt.constraint)
+		}
+		if !errors.Is(got, tt.err) && errors.Unwrap(got) != tt.err {
+			t.Errorf("%s: translated error does not wrap the original", tt.name)
+		}
+	}
+}
+
+func TestTranslateIdempotent(t *testing.T) {
+	if Translate(nil) != nil {
+		t.Error("Translate(nil) != nil")
+	}
+	once := Translate(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'k'"})
+	if twice := Translate(once); twice != once {
+		t.Errorf("Translate twice = %v, want %v", twice, once)
+	}
+	wrapped := fmt.Errorf("outer: %w", once)
+	if got := Translate(wrapped); got != wrapped {
+		t.Errorf("Translate wrapped = %v, want it unchanged", got)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	if c := Constraint(errors.New("plain")); c != "" {
+		t.Errorf("Constraint(plain) = %q", c)
+	}
+	if c := Constraint(nil); c != "" {
+		t.Errorf("Constraint(nil) = %q", c)
+	}
+	err := fmt.Errorf("create: %w", &Error{Kind: ErrConflict, Constraint: "uix_x", Err: errors.New("dup")})
+	if c := Constraint(err); c != "uix_x" {
+		t.Errorf("Constraint(wrapped) = %q, want uix_x", c)
+	}
+}
+
+func TestMySQLDupKey(t *testing.T) {
+	tests := map[string]string{
+		"Duplicate entry 'a' for key 'uix_users_tenant_name'":       "uix_users_tenant_name",
+		"Duplicate entry 'a' for key 'users.uix_users_tenant_name'": "uix_users_tenant_name",
+		"Duplicate entry 'it''s' for key 'PRIMARY'":                 "PRIMARY",
+		"something else": "",
+	}
+	for msg, want := range tests {
+		if got := mysqlDupKey(msg); got != want {
+			t.Errorf("mysqlDupKey(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	saved := translators
+	defer func() { translators = saved }()
+	custom := errors.New("custom driver error")
+	Register(func(err error) *Error {
+		if err == custom {
+			return &Error{Kind: ErrUnavailable, Err: err}
+		}
+		return nil
+	})
+	if got := Translate(custom); !errors.Is(got, ErrUnavailable) {
+		t.Errorf("Translate with registered translator = %v, want unavailable", got)
+	}
+	if got := Translate(gorm.ErrRecordNotFound); !errors.Is(got, ErrNotFound) {
+		t.Errorf("built-in translators stopped working: %v", got)
+	}
+}
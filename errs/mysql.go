@@ -0,0 +1,41 @@
+package errs
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// translateMySQL 按 MySQL 错误码翻译
+func translateMySQL(err error) *Error {
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return nil
+	}
+	switch me.Number {
+	case 1062, 1586: // ER_DUP_ENTRY
+		return &Error{Kind: ErrConflict, Constraint: mysqlDupKey(me.Message), Err: err}
+	case 1048, 1364, 1406, 1451, 1452, 3819: // not null、无默认值、超长、外键、check
+		return &Error{Kind: ErrConstraint, Err: err}
+	case 1040, 1205, 1213, 2002, 2003, 2006, 2013: // 连接数、锁超时、死锁、连接断开
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	return nil
+}
+
+// mysqlDupKey 从 "Duplicate entry 'x' for key 'uix_users_tenant_name'" 里取出索引名
+func mysqlDupKey(msg string) string {
+	i := strings.LastIndex(msg, "for key '")
+	if i < 0 {
+		return ""
+	}
+	key := strings.TrimSuffix(msg[i+len("for key '"):], "'")
+	if j := strings.LastIndexByte(key, '.'); j >= 0 { // MySQL 8 会带上表名
+		key = key[j+1:]
+	}
+	return key
+}
@@ -0,0 +1,27 @@
+package errs
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// translatePostgres 按 SQLSTATE 翻译
+func translatePostgres(err error) *Error {
+	var pe *pq.Error
+	if !errors.As(err, &pe) {
+		return nil
+	}
+	switch {
+	case pe.Code == "23505": // unique_violation
+		return &Error{Kind: ErrConflict, Constraint: pe.Constraint, Err: err}
+	case pe.Code.Class() == "23": // 其它完整性约束
+		return &Error{Kind: ErrConstraint, Constraint: pe.Constraint, Err: err}
+	case pe.Code == "22001": // string_data_right_truncation
+		return &Error{Kind: ErrConstraint, Constraint: pe.Column, Err: err}
+	case pe.Code.Class() == "08", pe.Code.Class() == "53", pe.Code.Class() == "57",
+		pe.Code == "40001", pe.Code == "40P01": // 连接、资源不足、被取消、序列化失败、死锁
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package errs
+
+import (
+	"strings"
+)
+
+// translateSQLite 按错误信息翻译，sqlite 驱动需要cgo，这里不直接依赖它的类型
+// 信息形如 "UNIQUE constraint failed: users.tenant_id, users.name"
+func translateSQLite(err error) *Error {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "UNIQUE constraint failed: "):
+		return &Error{Kind: ErrConflict, Constraint: strings.TrimPrefix(msg, "UNIQUE constraint failed: "), Err: err}
+	case strings.HasPrefix(msg, "NOT NULL constraint failed"),
+		strings.HasPrefix(msg, "FOREIGN KEY constraint failed"),
+		strings.HasPrefix(msg, "CHECK constraint failed"):
+		return &Error{Kind: ErrConstraint, Err: err}
+	case strings.HasPrefix(msg, "database is locked"), strings.HasPrefix(msg, "database table is locked"):
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	return nil
+}
@@ -7,11 +7,20 @@ require (
 	github.com/gin-contrib/sse v0.1.0
 	github.com/gin-gonic/gin v1.5.0
 	github.com/go-sql-driver/mysql v1.4.1
+	github.com/golang/protobuf v1.3.2
+	github.com/graph-gophers/dataloader v5.0.0+incompatible
+	github.com/graphql-go/graphql v0.8.1
 	github.com/jinzhu/gorm v1.9.11
 	github.com/json-iterator/go v1.1.7
+	github.com/lib/pq v1.1.1
 	github.com/mattn/go-isatty v0.0.9
+	github.com/mattn/go-sqlite3 v1.11.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd
 	github.com/modern-go/reflect2 v1.0.1
+	github.com/opentracing/opentracing-go v1.2.0 // indirect
+	github.com/swaggo/files v0.0.0-20190704085106-630677cd5c14
+	github.com/ugorji/go/codec v1.1.7
+	google.golang.org/grpc v1.19.0
 	gopkg.in/go-playground/validator.v8 v8.18.2
 	gopkg.in/yaml.v2 v2.2.2
 )
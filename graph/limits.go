@@ -0,0 +1,223 @@
+// Package graph GraphQL 查询执行前的检查：深度、复杂度
+// 只看查询本身和 schema，不执行任何 resolver
+package graph
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// 查询的限制，0表示不限制
+var (
+	MaxQueryLength  = 10000 // 查询字符串的最大长度
+	MaxDepth        = 6     // 字段最多嵌套几层，内省查询（__schema、__type）不计
+	MaxComplexity   = 5000  // 每个字段算1，列表字段的子字段乘以条数
+	DefaultListSize = 10    // 列表字段没有 first 参数、也没有默认值时按这么多条估算
+)
+
+// Cost 一个操作的深度和复杂度
+type Cost struct {
+	Depth      int
+	Complexity int
+}
+
+// Check 检查查询有没有超过限制，需要在 graphql.ValidateDocument 通过之后调用
+func Check(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	cost := Measure(schema, doc, operationName, variables)
+	if MaxDepth > 0 && cost.Depth > MaxDepth {
+		return fmt.Errorf("查询嵌套 %d 层，超过上限 %d", cost.Depth, MaxDepth)
+	}
+	if MaxComplexity > 0 && cost.Complexity > MaxComplexity {
+		return fmt.Errorf("查询复杂度 %d，超过上限 %d", cost.Complexity, MaxComplexity)
+	}
+	return nil
+}
+
+// Measure 计算要执行的操作的深度和复杂度，找不到操作时返回0
+func Measure(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) Cost {
+	m := measurer{schema: schema, variables: variables, defaults: map[string]ast.Value{},
+		fragments: map[string]*ast.FragmentDefinition{}, visiting: map[string]bool{}}
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || def.Name != nil && def.Name.Value == operationName {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		return Cost{}
+	}
+	for _, v := range op.VariableDefinitions { //没传的变量用操作里写的默认值
+		if v.DefaultValue != nil {
+			m.defaults[v.Variable.Name.Value] = v.DefaultValue
+		}
+	}
+	var root graphql.Type = schema.QueryType()
+	switch op.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	}
+	return m.selectionSet(op.SelectionSet, root, false)
+}
+
+type measurer struct {
+	schema    graphql.Schema
+	variables map[string]interface{}
+	defaults  map[string]ast.Value // 操作里变量的默认值，如 query($n: Int = 500)
+	fragments map[string]*ast.FragmentDefinition
+	visiting  map[string]bool // 正在展开的片段，防止片段互相引用时死循环
+}
+
+// fielder Object 和 Interface 都可以按名字查字段
+type fielder interface {
+	Fields() graphql.FieldDefinitionMap
+}
+
+// selectionSet 子字段里最深的一个决定深度，复杂度是所有子字段相加
+func (m *measurer) selectionSet(set *ast.SelectionSet, parent graphql.Type, introspection bool) (cost Cost) {
+	if set == nil {
+		return
+	}
+	for _, sel := range set.Selections {
+		var c Cost
+		switch sel := sel.(type) {
+		case *ast.Field:
+			c = m.field(sel, parent, introspection)
+		case *ast.InlineFragment:
+			c = m.selectionSet(sel.SelectionSet, m.typeCondition(sel.TypeCondition, parent), introspection)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			fragment, ok := m.fragments[name]
+			if !ok || m.visiting[name] {
+				continue
+			}
+			m.visiting[name] = true
+			c = m.selectionSet(fragment.SelectionSet, m.typeCondition(fragment.TypeCondition, parent), introspection)
+			m.visiting[name] = false
+		}
+		if c.Depth > cost.Depth {
+			cost.Depth = c.Depth
+		}
+		cost.Complexity = capped(cost.Complexity + c.Complexity)
+	}
+	return
+}
+
+func (m *measurer) field(f *ast.Field, parent graphql.Type, introspection bool) Cost {
+	name := f.Name.Value
+	if name == "__typename" {
+		return Cost{}
+	}
+	if strings.HasPrefix(name, "__") {
+		introspection = true
+	}
+	var def *graphql.FieldDefinition
+	if p, ok := parent.(fielder); ok {
+		def = p.Fields()[name]
+	}
+	var child graphql.Type
+	size := 1
+	if def != nil {
+		child, _ = graphql.GetNamed(def.Type).(graphql.Type)
+		if m.isList(def.Type) {
+			size = m.listSize(f, def)
+		}
+	}
+	children := m.selectionSet(f.SelectionSet, child, introspection)
+	cost := Cost{Depth: children.Depth, Complexity: capped(1 + capped(size)*children.Complexity)}
+	if !introspection {
+		cost.Depth++
+	}
+	return cost
+}
+
+func (m *measurer) isList(t graphql.Type) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	_, ok := t.(*graphql.List)
+	return ok
+}
+
+// listSize 列表的条数：first 参数，其次是参数的默认值，都没有时用 DefaultListSize
+// first 是变量时用传进来的值，没传时用变量的默认值
+func (m *measurer) listSize(f *ast.Field, def *graphql.FieldDefinition) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, ok := intValue(v); ok {
+				return n
+			}
+		case *ast.Variable:
+			name := v.Name.Value
+			if value, ok := m.variables[name]; ok {
+				if n, ok := toInt(value); ok {
+					return n
+				}
+			} else if n, ok := intValue(m.defaults[name]); ok {
+				return n
+			}
+		}
+	}
+	for _, arg := range def.Args {
+		if arg.Name() == "first" {
+			if n, ok := toInt(arg.DefaultValue); ok {
+				return n
+			}
+		}
+	}
+	return DefaultListSize
+}
+
+func (m *measurer) typeCondition(named *ast.Named, parent graphql.Type) graphql.Type {
+	if named == nil {
+		return parent
+	}
+	return m.schema.Type(named.Name.Value)
+}
+
+// capped 复杂度最大按 math.MaxInt32 算，first 传很大的数时乘法不会溢出成负数
+func capped(n int) int {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return n
+}
+
+// intValue 查询里写的整数
+func intValue(v ast.Value) (int, bool) {
+	iv, ok := v.(*ast.IntValue)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(iv.Value)
+	if err != nil { //超过 int 范围
+		return math.MaxInt32, !strings.HasPrefix(iv.Value, "-")
+	}
+	return n, n >= 0
+}
+
+// toInt 变量来自 JSON 时是 float64
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, v >= 0
+	case float64:
+		return int(math.Min(v, math.MaxInt32)), v >= 0
+	}
+	return 0, false
+}
@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// testSchema users 的 first 有默认值，friends 的没有
+func testSchema(t *testing.T) graphql.Schema {
+	var user *graphql.Object
+	user = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   {Type: graphql.Int},
+				"name": {Type: graphql.String},
+				"friends": {
+					Type: graphql.NewNonNull(graphql.NewList(user)),
+					Args: graphql.FieldConfigArgument{"first": {Type: graphql.Int}},
+				},
+			}
+		}),
+	})
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": {Type: user},
+			"users": {
+				Type: graphql.NewList(user),
+				Args: graphql.FieldConfigArgument{"first": {Type: graphql.Int, DefaultValue: 50}},
+			},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	return schema
+}
+
+func parse(t *testing.T, query string) *ast.Document {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		t.Fatalf("parse %q: %v", query, err)
+	}
+	return doc
+}
+
+func TestMeasure(t *testing.T) {
+	schema := testSchema(t)
+	tests := []struct {
+		query     string
+		operation string
+		variables map[string]interface{}
+		want      Cost
+	}{
+		{query: `{ me { id name } }`, want: Cost{Depth: 2, Complexity: 3}},
+		{query: `{ me { id __typename } }`, want: Cost{Depth: 2, Complexity: 2}},
+		{query: `{ users(first: 3) { id name } }`, want: Cost{Depth: 2, Complexity: 1 + 3*2}},
+		// 参数的默认值
+		{query: `{ users { id } }`, want: Cost{Depth: 2, Complexity: 1 + 50}},
+		// 没有默认值时用 DefaultListSize
+		{query: `{ me { friends { id } } }`, want: Cost{Depth: 3, Complexity: 1 + 1 + DefaultListSize}},
+		{query: `{ me { friends(first: 2) { friends(first: 4) { id } } } }`, want: Cost{Depth: 4, Complexity: 1 + 1 + 2*(1+4)}},
+		// 变量
+		{query: `query($n: Int) { users(first: $n) { id } }`, variables: map[string]interface{}{"n": float64(5)}, want: Cost{Depth: 2, Complexity: 1 + 5}},
+		{query: `query($n: Int) { users(first: $n) { id } }`, want: Cost{Depth: 2, Complexity: 1 + 50}},
+		// 没传变量时用操作里的默认值，而不是字段参数的默认值
+		{query: `query($n: Int = 500) { users(first: $n) { id } }`, want: Cost{Depth: 2, Complexity: 1 + 500}},
+		{query: `query($n: Int = 500) { users(first: $n) { id } }`, variables: map[string]interface{}{"n": 2}, want: Cost{Depth: 2, Complexity: 1 + 2}},
+		{query: `query($n: Int = 400) { me { friends(first: $n) { id } } }`, want: Cost{Depth: 3, Complexity: 1 + 1 + 400}},
+		// 片段和内联片段
+		{query: `{ users(first: 2) { ...f } } fragment f on User { id name }`, want: Cost{Depth: 2, Complexity: 1 + 2*2}},
+		{query: `{ me { ... on User { friends(first: 3) { id } } } }`, want: Cost{Depth: 3, Complexity: 1 + 1 + 3}},
+		// 片段互相引用时不会死循环
+		{query: `{ me { ...a } } fragment a on User { id ...b } fragment b on User { name ...a }`, want: Cost{Depth: 2, Complexity: 3}},
+		// 内省查询不计深度
+		{query: `{ __schema { types { fields { type { name } } } } }`, want: Cost{Depth: 0, Complexity: 5}},
+		// 按 operationName 选操作，找不到时是0
+		{query: `query a { me { id } } query b { users(first: 7) { id } }`, operation: "b", want: Cost{Depth: 2, Complexity: 1 + 7}},
+		{query: `query a { me { id } }`, operation: "missing", want: Cost{}},
+	}
+	for _, tt := range tests {
+		got := Measure(schema, parse(t, tt.query), tt.operation, tt.variables)
+		if got != tt.want {
+			t.Errorf("Measure(%q, %v) = %+v, want %+v", tt.query, tt.variables, got, tt.want)
+		}
+	}
+}
+
+// first 很大时复杂度封顶，不会溢出成负数
+func TestMeasureCapped(t *testing.T) {
+	schema := testSchema(t)
+	query := `query($n: Int) { users(first: $n) { friends(first: $n) { friends(first: $n) { id } } } }`
+	got := Measure(schema, parse(t, query), "", map[string]interface{}{"n": float64(math.MaxInt32)})
+	if got.Complexity != math.MaxInt32 {
+		t.Errorf("Complexity = %d, want %d", got.Complexity, math.MaxInt32)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	schema := testSchema(t)
+	defer func(depth, complexity int) { MaxDepth, MaxComplexity = depth, complexity }(MaxDepth, MaxComplexity)
+	MaxDepth, MaxComplexity = 3, 100
+
+	tests := []struct {
+		query string
+		want  string // 期望的错误里包含的内容，空表示通过
+	}{
+		{`{ me { friends(first: 2) { id } } }`, ""},
+		{`{ me { friends(first: 2) { friends(first: 2) { id } } } }`, "嵌套 4 层"},
+		{`{ users(first: 99) { id } }`, ""},
+		{`{ users(first: 100) { id } }`, "复杂度 101"},
+		{`query($n: Int = 500) { users(first: $n) { id } }`, "复杂度 501"},
+	}
+	for _, tt := range tests {
+		err := Check(schema, parse(t, tt.query), "", nil)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("Check(%q) = %v, want nil", tt.query, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("Check(%q) = %v, want an error containing %q", tt.query, err, tt.want)
+		}
+	}
+
+	MaxDepth, MaxComplexity = 0, 0 //0表示不限制
+	if err := Check(schema, parse(t, `query($n: Int = 100000) { users(first: $n) { friends { friends { friends { id } } } } }`), "", nil); err != nil {
+		t.Errorf("Check without limits = %v, want nil", err)
+	}
+}
@@ -0,0 +1,115 @@
+package grpcapi
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/policy"
+	"github.com/xdtest/project/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type claimsKey struct{}
+
+// PublicMethods 不需要token的方法
+var PublicMethods = map[string]bool{
+	"/project.v1.UserService/Login":         true,
+	"/project.v1.UserService/ValidateToken": true,
+}
+
+// ImpersonationAllowlist 模拟登录时允许调用的方法，和 jwt.ImpersonationAllowlist 对应，没有列出的一律拒绝
+var ImpersonationAllowlist = map[string]bool{
+	"/project.v1.UserService/GetUser":   true,
+	"/project.v1.UserService/ListUsers": true,
+}
+
+// authenticate 从 metadata 的 authorization: Bearer <token> 取出token，检查后把 claims 放进 ctx
+func authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if PublicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	token := metadataValue(ctx, "authorization")
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = token[7:]
+	}
+	claims, err := parseToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ImpersonatorId != 0 {
+		blocked := !ImpersonationAllowlist[info.FullMethod]
+		entry := models.AuditLog{
+			TenantId:   claims.Tenant,
+			ActorId:    claims.ImpersonatorId,
+			ActorName:  claims.ImpersonatorName + " as " + claims.Name,
+			Action:     models.AuditImpersonateAction,
+			TargetType: "user",
+			TargetId:   claims.ID,
+			RequestId:  getRequestID(ctx),
+		}
+		if err := models.AppendAudit(&entry, nil, map[string]interface{}{"route": info.FullMethod, "blocked": blocked}); err != nil {
+			log.Println("append impersonation audit log error", err)
+			return nil, status.Error(codes.Unavailable, "审计日志写入失败，请稍后重试")
+		}
+		if blocked {
+			return nil, status.Error(codes.PermissionDenied, "模拟登录时不允许该操作")
+		}
+	}
+	return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+}
+
+// parseToken 和 jwt.JWTAuth 一样用 jwt.ParseToken 解析，并检查会话有没有被吊销
+func parseToken(ctx context.Context, token string) (*jwt.CustomClaims, error) {
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "请求未携带token，无权限访问")
+	}
+	claims, err := jwt.NewJWT().ParseToken(token)
+	if err == jwt.TokenExpired {
+		if claims != nil {
+			ip, userAgent := clientInfo(ctx)
+			models.RecordSecurityEvent(&models.SecurityEvent{
+				UserId:    claims.ID,
+				Account:   claims.Name,
+				Type:      models.EventTokenExpired,
+				IP:        ip,
+				UserAgent: userAgent,
+			})
+		}
+		return nil, status.Error(codes.Unauthenticated, "授权已过期")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if _, err := models.FindActiveSession(claims.Id); err != nil {
+		return nil, status.Error(codes.Unauthenticated, "会话已失效，请重新登录")
+	}
+	return claims, nil
+}
+
+// claimsFrom 已认证的调用里当前用户的 claims
+func claimsFrom(ctx context.Context) *jwt.CustomClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*jwt.CustomClaims)
+	return claims
+}
+
+// requireOwnerOr 和 policy.RequireOwnerOr 一样：只能操作自己，拥有 perm 权限的可以操作任何人
+// targetId 为0时必须有 perm 权限
+func requireOwnerOr(ctx context.Context, perm string, targetId int) error {
+	claims := claimsFrom(ctx)
+	if !policy.Allowed(claims, perm, targetId) {
+		return status.Error(codes.PermissionDenied, "无权限操作该用户")
+	}
+	return nil
+}
+
+// requireVerifiedEmail limited 策略下未验证邮箱不能修改资料，和 jwt.RequireVerifiedEmail 对应
+func requireVerifiedEmail(ctx context.Context) error {
+	if models.EmailPolicy == models.EmailPolicyLimited && !claimsFrom(ctx).EmailVerified {
+		return status.Error(codes.PermissionDenied, "邮箱未验证，无权限访问")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package grpcapi
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/xdtest/project/middleware/errhandler"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpCodes HTTP 状态码对应的 gRPC 状态码，错误的分类和提示沿用 errhandler 里注册的映射
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:           codes.InvalidArgument,
+	http.StatusUnauthorized:         codes.Unauthenticated,
+	http.StatusForbidden:            codes.PermissionDenied,
+	http.StatusNotFound:             codes.NotFound,
+	http.StatusConflict:             codes.AlreadyExists,
+	http.StatusPreconditionFailed:   codes.FailedPrecondition,
+	http.StatusUnprocessableEntity:  codes.InvalidArgument,
+	http.StatusPreconditionRequired: codes.FailedPrecondition,
+	http.StatusTooManyRequests:      codes.ResourceExhausted,
+	http.StatusServiceUnavailable:   codes.Unavailable,
+}
+
+// toStatus 把业务错误转换成 gRPC 状态，已经是 gRPC 状态的原样返回
+// 服务端错误只返回笼统的提示，详细错误记日志
+func toStatus(ctx context.Context, method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	httpStatus, msg := errhandler.Resolve(err)
+	code, ok := httpCodes[httpStatus]
+	if !ok {
+		code = codes.Internal
+	}
+	if httpStatus >= 500 {
+		log.Printf("grpc error request_id=%s method=%s code=%s error=%q", getRequestID(ctx), method, code, err.Error())
+	}
+	return status.Error(code, msg)
+}
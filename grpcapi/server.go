@@ -0,0 +1,105 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"runtime/debug"
+
+	"github.com/xdtest/project/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// RequestIDHeader 请求ID使用的 metadata，和 HTTP 的 X-Request-ID 一样，客户端带了就沿用
+const RequestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// NewServer 创建 gRPC 服务并注册 UserService
+func NewServer() *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(chain(requestID, handleErrors, authenticate)))
+	pb.RegisterUserServiceServer(s, &UserServer{})
+	return s
+}
+
+// Serve 在 addr 上提供 gRPC 服务，和 HTTP 在同一个进程里运行
+func Serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Println("grpc listening on", addr)
+	return NewServer().Serve(lis)
+}
+
+// chain 把多个拦截器串起来，按顺序执行
+func chain(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, h := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// requestID 给每个调用分配一个ID，写进审计日志并通过响应头返回
+func requestID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := metadataValue(ctx, RequestIDHeader)
+	if id == "" || len(id) > 64 {
+		buf := make([]byte, 16)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+	return handler(context.WithValue(ctx, requestIDKey{}, id), req)
+}
+
+// handleErrors 和 HTTP 的 errhandler 一样：panic 不会让进程退出，错误转换成 gRPC 状态码
+func handleErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic request_id=%s method=%s error=%q stack=%q",
+				getRequestID(ctx), info.FullMethod, fmt.Sprint(r), debug.Stack())
+			resp, err = nil, toStatus(ctx, info.FullMethod, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	resp, err = handler(ctx, req)
+	if err != nil {
+		err = toStatus(ctx, info.FullMethod, err)
+	}
+	return
+}
+
+func getRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// metadataValue 取请求 metadata 里的第一个值
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// clientInfo 调用方的IP和 user-agent，记录安全事件、会话时用
+func clientInfo(ctx context.Context) (ip, userAgent string) {
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	return ip, metadataValue(ctx, "user-agent")
+}
@@ -0,0 +1,409 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/policy"
+	"github.com/xdtest/project/models"
+	"github.com/xdtest/project/pb"
+	"github.com/xdtest/project/security"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testPassword = "Correct-Horse-42"
+
+// testMailer 记下发出的邮件
+type testMailer struct{ sent []mailer.Message }
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// startServer 用内存里的 sqlite 和 bufconn 启动服务，返回客户端和清理函数
+func startServer(t *testing.T) (pb.UserServiceClient, func()) {
+	if err := policy.Default.Load("../config/policies.yaml"); err != nil {
+		t.Fatalf("load policies.yaml: %v", err)
+	}
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.DB().SetMaxOpenConns(1) //每个连接是一个单独的内存数据库
+	db.AutoMigrate(&models.User{}, &models.PasswordHistory{}, &models.Session{}, &models.SecurityEvent{},
+		&models.AuditLog{}, &models.Organization{}, &models.Membership{}, &models.EmailVerification{},
+		&models.UserEvent{}, &models.Webhook{}, &models.WebhookDelivery{})
+	prevDB := orm.Eloquent
+	models.UseDB(db)
+	if err := models.EnsureDefaultOrganization(); err != nil {
+		t.Fatalf("create default organization: %v", err)
+	}
+	prevDuration := security.AuthFailureMinDuration
+	security.AuthFailureMinDuration = 0 //需要时在测试里设
+
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer()
+	go s.Serve(lis)
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return pb.NewUserServiceClient(conn), func() {
+		conn.Close()
+		s.Stop()
+		orm.Eloquent = prevDB
+		security.AuthFailureMinDuration = prevDuration
+		db.Close()
+	}
+}
+
+func createUser(t *testing.T, name string, role int) models.User {
+	u := models.User{Name: name, Password: testPassword, Role: role, TenantId: models.DefaultTenantId, Status: models.UserActive}
+	if _, err := u.Adduser(nil); err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return u
+}
+
+// tokenFor 给用户建一个会话并签发token，impersonator 不为nil时是管理员模拟登录
+func tokenFor(t *testing.T, u models.User, impersonator *models.User) string {
+	session, err := u.CreateSession("test", "go-test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	claims := jwt.NewUserClaims(u, u.Role, session.TokenId, 3600)
+	if impersonator != nil {
+		claims.ImpersonatorId = impersonator.Id
+		claims.ImpersonatorName = impersonator.Name
+	}
+	token, err := jwt.NewJWT().CreateToken(claims)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	return token
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func wantCode(t *testing.T, what string, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Errorf("%s: code = %v (%v), want %v", what, got, err, code)
+	}
+}
+
+// 列表里的方法名写错了就永远匹配不上，这里和服务实际的方法对一遍
+func TestMethodListsMatchService(t *testing.T) {
+	methods := map[string]bool{}
+	for name, info := range NewServer().GetServiceInfo() {
+		for _, m := range info.Methods {
+			methods["/"+name+"/"+m.Name] = true
+		}
+	}
+	for _, list := range []map[string]bool{PublicMethods, ImpersonationAllowlist} {
+		for m := range list {
+			if !methods[m] {
+				t.Errorf("%s is not a method of the service", m)
+			}
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	alice := createUser(t, "alice", models.RoleUser)
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{})
+	wantCode(t, "without token", err, codes.Unauthenticated)
+	_, err = client.GetUser(withToken("not-a-token"), &pb.GetUserRequest{})
+	wantCode(t, "bad token", err, codes.Unauthenticated)
+
+	// PublicMethods 不需要token
+	tok, err := client.Login(context.Background(), &pb.LoginRequest{Name: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	info, err := client.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: tok.Token})
+	if err != nil || info.UserId != int64(alice.Id) {
+		t.Fatalf("ValidateToken = %v, %v, want user %d", info, err, alice.Id)
+	}
+
+	me, err := client.GetUser(withToken(tok.Token), &pb.GetUserRequest{})
+	if err != nil || me.Name != "alice" {
+		t.Fatalf("GetUser with token = %v, %v, want alice", me, err)
+	}
+	// 普通用户不能列出所有人
+	_, err = client.ListUsers(withToken(tok.Token), &pb.ListUsersRequest{})
+	wantCode(t, "ListUsers as user", err, codes.PermissionDenied)
+
+	// 吊销会话后token不能再用
+	if _, err := models.RevokeOtherSessions(alice.Id, ""); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	_, err = client.GetUser(withToken(tok.Token), &pb.GetUserRequest{})
+	wantCode(t, "revoked session", err, codes.Unauthenticated)
+}
+
+// token 只签名不加密，不能带上密码
+func TestTokenHasNoPassword(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	createUser(t, "alice", models.RoleUser)
+	tok, err := client.Login(context.Background(), &pb.LoginRequest{Name: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(tok.Token, ".")[1])
+	if err != nil {
+		t.Fatalf("decode token: %v", err)
+	}
+	if strings.Contains(string(payload), testPassword) {
+		t.Errorf("token payload contains the password: %s", payload)
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	admin := createUser(t, "admin", models.RoleAdmin)
+	alice := createUser(t, "alice", models.RoleUser)
+	ctx := withToken(tokenFor(t, alice, &admin))
+
+	if _, err := client.GetUser(ctx, &pb.GetUserRequest{}); err != nil {
+		t.Errorf("GetUser while impersonating: %v", err)
+	}
+	_, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(alice.Id), Version: 1,
+		User: &pb.UserInput{Name: &wrappers.StringValue{Value: "mallory"}}})
+	wantCode(t, "UpdateUser while impersonating", err, codes.PermissionDenied)
+	if u, _ := models.GetUser(alice.Id); u.Name != "alice" {
+		t.Errorf("name = %q after a blocked update, want alice", u.Name)
+	}
+
+	// 允许的和被拒绝的调用都要记审计日志
+	logs, err := models.QueryAuditLogs(models.AuditFilter{Action: models.AuditImpersonateAction})
+	if err != nil {
+		t.Fatalf("query audit logs: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d impersonation audit logs, want 2", len(logs))
+	}
+	for _, l := range logs {
+		if l.ActorId != admin.Id || l.TargetId != alice.Id {
+			t.Errorf("audit log actor %d target %d, want %d and %d", l.ActorId, l.TargetId, admin.Id, alice.Id)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	createUser(t, "alice", models.RoleUser)
+	login := func(pw string) error {
+		_, err := client.Login(context.Background(), &pb.LoginRequest{Name: "alice", Password: pw})
+		return err
+	}
+
+	if err := login(testPassword); err != nil {
+		t.Fatalf("Login before lockout: %v", err)
+	}
+	for i := 0; i < models.LockoutThreshold; i++ {
+		wantCode(t, "wrong password", login("wrong-password-1"), codes.Unauthenticated)
+	}
+	// 锁定后密码正确也不能登录，提示和密码错误一样
+	err := login(testPassword)
+	wantCode(t, "Login after lockout", err, codes.Unauthenticated)
+	if msg := status.Convert(err).Message(); msg != "用户名或密码错误" {
+		t.Errorf("lockout message = %q, want the wrong password message", msg)
+	}
+	wantCode(t, "unknown user", func() error {
+		_, err := client.Login(context.Background(), &pb.LoginRequest{Name: "nobody", Password: testPassword})
+		return err
+	}(), codes.Unauthenticated)
+}
+
+func TestUpdateUserSendsVerificationMail(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	m := &testMailer{}
+	prevMailer := mailer.Default
+	mailer.Default = m
+	defer func() { mailer.Default = prevMailer }()
+	alice := createUser(t, "alice", models.RoleUser)
+	ctx := withToken(tokenFor(t, alice, nil))
+
+	_, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(alice.Id), User: &pb.UserInput{}})
+	wantCode(t, "UpdateUser without version", err, codes.FailedPrecondition)
+
+	u, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(alice.Id), Version: 1,
+		User: &pb.UserInput{Email: &wrappers.StringValue{Value: "Alice@Example.com"}}})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if u.EmailVerifiedAt != nil {
+		t.Errorf("email_verified_at = %v after changing the email, want unset", u.EmailVerifiedAt)
+	}
+	if len(m.sent) != 1 || m.sent[0].To != "alice@example.com" {
+		t.Fatalf("sent %+v, want one verification mail to alice@example.com", m.sent)
+	}
+
+	// 邮箱没变不再发
+	if _, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(alice.Id), Version: u.Version,
+		User: &pb.UserInput{Name: &wrappers.StringValue{Value: "alice2"}}}); err != nil {
+		t.Fatalf("UpdateUser name: %v", err)
+	}
+	if len(m.sent) != 1 {
+		t.Errorf("sent %d mails, want 1", len(m.sent))
+	}
+}
+
+// 审计日志和用户事件和修改在同一个事务里写，修改失败时都不写；验证邮箱也要有 user.updated
+func TestUpdateUserWritesEvents(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	m := &testMailer{}
+	prevMailer := mailer.Default
+	mailer.Default = m
+	defer func() { mailer.Default = prevMailer }()
+	alice := createUser(t, "alice", models.RoleUser)
+	ctx := withToken(tokenFor(t, alice, nil))
+	events := func() []models.UserEvent {
+		list, err := models.ListUserEvents(models.UserEventFilter{UserId: alice.Id})
+		if err != nil {
+			t.Fatalf("list user events: %v", err)
+		}
+		return list
+	}
+
+	u, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(alice.Id), Version: 1,
+		User: &pb.UserInput{Email: &wrappers.StringValue{Value: "alice@example.com"}}})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if list := events(); len(list) != 1 || list[0].Type != models.UserUpdated {
+		t.Fatalf("events after UpdateUser = %+v, want one %s", list, models.UserUpdated)
+	}
+	logs, err := models.QueryAuditLogs(models.AuditFilter{Action: models.AuditUserUpdate, TargetId: alice.Id})
+	if err != nil || len(logs) != 1 || logs[0].ActorId != alice.Id {
+		t.Fatalf("audit logs = %+v, %v, want one update by alice", logs, err)
+	}
+
+	_, err = client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(alice.Id), Version: u.Version + 1,
+		User: &pb.UserInput{Name: &wrappers.StringValue{Value: "alice2"}}})
+	wantCode(t, "UpdateUser with a stale version", err, codes.FailedPrecondition)
+	if list := events(); len(list) != 1 {
+		t.Errorf("got %d events after a failed update, want 1", len(list))
+	}
+
+	if len(m.sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(m.sent))
+	}
+	token := m.sent[0].Body[strings.LastIndex(m.sent[0].Body, "token=")+len("token="):]
+	if _, err := models.VerifyEmail(token, &models.AuditLog{Action: models.AuditUserUpdate, TargetType: "user"}); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	list := events()
+	if len(list) != 2 || list[1].Type != models.UserUpdated || !strings.Contains(list[1].Data, `"email_verified_at":"`) {
+		t.Fatalf("events after VerifyEmail = %+v, want a second %s with email_verified_at", list, models.UserUpdated)
+	}
+	logs, err = models.QueryAuditLogs(models.AuditFilter{Action: models.AuditUserUpdate, TargetId: alice.Id})
+	if err != nil || len(logs) != 2 || logs[0].ActorId != alice.Id {
+		t.Errorf("audit logs after VerifyEmail = %+v, %v, want a second one by alice", logs, err)
+	}
+}
+
+func TestInvalidEmail(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	m := &testMailer{}
+	prevMailer := mailer.Default
+	mailer.Default = m
+	defer func() { mailer.Default = prevMailer }()
+	admin := createUser(t, "admin", models.RoleAdmin)
+	ctx := withToken(tokenFor(t, admin, nil))
+
+	for _, email := range []string{"alice", "alice@", "Alice <alice@example.com>", "a@example.com, b@example.com", "alice@example.com\nbcc: x@example.com"} {
+		_, err := client.CreateUser(ctx, &pb.CreateUserRequest{User: &pb.UserInput{
+			Name:     &wrappers.StringValue{Value: "alice"},
+			Password: &wrappers.StringValue{Value: testPassword},
+			Email:    &wrappers.StringValue{Value: email},
+		}})
+		wantCode(t, "CreateUser with email "+email, err, codes.InvalidArgument)
+		_, err = client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: int64(admin.Id), Version: 1,
+			User: &pb.UserInput{Email: &wrappers.StringValue{Value: email}}})
+		wantCode(t, "UpdateUser with email "+email, err, codes.InvalidArgument)
+	}
+	if len(m.sent) != 0 {
+		t.Errorf("sent %d verification mails to invalid addresses", len(m.sent))
+	}
+	if _, err := client.CreateUser(ctx, &pb.CreateUserRequest{User: &pb.UserInput{
+		Name:     &wrappers.StringValue{Value: "alice"},
+		Password: &wrappers.StringValue{Value: testPassword},
+		Email:    &wrappers.StringValue{Value: " Alice@Example.com "},
+	}}); err != nil {
+		t.Errorf("CreateUser with a valid email: %v", err)
+	}
+}
+
+// 和 POST /login 一样补齐失败的耗时，新设备登录发邮件提醒
+func TestLoginTimingAndNewDevice(t *testing.T) {
+	client, stop := startServer(t)
+	defer stop()
+	security.AuthFailureMinDuration = 50 * time.Millisecond
+	m := &testMailer{}
+	prevMailer := mailer.Default
+	mailer.Default = m
+	defer func() { mailer.Default = prevMailer }()
+	u := models.User{Name: "alice", Password: testPassword, TenantId: models.DefaultTenantId, Status: models.UserActive}
+	u.SetEmail("alice@example.com")
+	if _, err := u.Adduser(nil); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	for _, req := range []*pb.LoginRequest{{Name: "nobody", Password: testPassword}, {Name: "alice", Password: "wrong-password-1"}} {
+		start := time.Now()
+		_, err := client.Login(context.Background(), req)
+		wantCode(t, "Login "+req.Name, err, codes.Unauthenticated)
+		if d := time.Since(start); d < security.AuthFailureMinDuration {
+			t.Errorf("Login %s failed in %v, want at least %v", req.Name, d, security.AuthFailureMinDuration)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Login(context.Background(), &pb.LoginRequest{Name: "alice", Password: testPassword}); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+	}
+	events, err := models.ListSecurityEvents(u.Id, 100, 0)
+	if err != nil {
+		t.Fatalf("list security events: %v", err)
+	}
+	n := 0
+	for _, e := range events {
+		if e.Type == models.EventNewDevice {
+			n++
+		}
+	}
+	if n != 1 || len(m.sent) != 1 || m.sent[0].Subject != "新设备登录提醒" {
+		t.Errorf("got %d new device events and mails %+v, want one of each", n, m.sent)
+	}
+}
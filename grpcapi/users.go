@@ -0,0 +1,279 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/xdtest/project/apis"
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/policy"
+	"github.com/xdtest/project/models"
+	"github.com/xdtest/project/pb"
+	"github.com/xdtest/project/security"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 分页大小
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// TokenTTL Login 签发的token有效秒数，和 HTTP 登录一样
+var TokenTTL int64 = 3600
+
+// UserServer 实现 pb.UserServiceServer，和 apis 一样直接调用 models
+// 新建用户、修改邮箱时和 HTTP 接口一样发验证邮件
+type UserServer struct{}
+
+// CreateUser 在当前组织新建用户，对应 POST /v1/users
+func (s *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	if err := requireOwnerOr(ctx, policy.PermUserCreateAny, 0); err != nil {
+		return nil, err
+	}
+	fields := req.GetUser().Fields()
+	var user models.User
+	user.Name, _ = fields["name"].(string)
+	user.Password, _ = fields["password"].(string)
+	email, _ := fields["email"].(string)
+	user.SetEmail(email)
+	user.TenantId = claimsFrom(ctx).Tenant
+	user.Status = models.UserActive
+	if user.Name == "" || user.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "用户名和密码不能为空")
+	}
+	id, err := user.Adduser(auditEntry(ctx, models.AuditUserCreate, 0))
+	if err != nil {
+		if errors.Is(err, errs.ErrConflict) {
+			msg := "用户名已存在"
+			if strings.Contains(errs.Constraint(err), "email") {
+				msg = "邮箱已被使用"
+			}
+			return nil, status.Error(codes.AlreadyExists, msg)
+		}
+		return nil, err
+	}
+	user.Id = id
+	if user.Email != nil {
+		if err := apis.SendVerificationMail(user); err != nil {
+			log.Println("send verification mail error", err)
+		}
+	}
+	return pb.NewUser(user), nil
+}
+
+// GetUser 查看用户，id 为0时是当前登录的用户，对应 GET /v1/users/:id 和 GET /v1/me
+func (s *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	id := int(req.GetId())
+	if id == 0 {
+		id = claimsFrom(ctx).ID
+	}
+	if err := requireOwnerOr(ctx, policy.PermUserReadAny, id); err != nil {
+		return nil, err
+	}
+	user, err := models.GetTenantUser(claimsFrom(ctx).Tenant, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return pb.NewUser(user), nil
+}
+
+// ListUsers 按id分页列出当前组织的用户，对应 GET /v1/users
+// page_token 是上一页最后一个用户的id，客户端不应该依赖它的格式
+func (s *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	if err := requireOwnerOr(ctx, policy.PermUserReadAny, 0); err != nil {
+		return nil, err
+	}
+	size := int(req.GetPageSize())
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	after := 0
+	if req.GetPageToken() != "" {
+		var err error
+		if after, err = strconv.Atoi(req.GetPageToken()); err != nil || after < 0 {
+			return nil, status.Error(codes.InvalidArgument, "page_token 无效")
+		}
+	}
+	// 多取一个，用来判断还有没有下一页
+	users, err := models.ListusersAfter(claimsFrom(ctx).Tenant, after, size+1)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListUsersResponse{}
+	if len(users) > size {
+		users = users[:size]
+		resp.NextPageToken = strconv.Itoa(users[size-1].Id)
+	}
+	resp.Users = pb.NewUserList(users).Users
+	return resp, nil
+}
+
+// UpdateUser 修改用户资料，没设置的字段不修改，对应 PATCH /v1/users/:id
+func (s *UserServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	id := int(req.GetId())
+	if err := requireVerifiedEmail(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireOwnerOr(ctx, policy.PermUserUpdateAny, id); err != nil {
+		return nil, err
+	}
+	if req.GetVersion() == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "请带上 version，避免覆盖别人的修改")
+	}
+	fields := req.GetUser().Fields()
+	if name, ok := fields["name"]; ok && name == "" {
+		return nil, status.Error(codes.InvalidArgument, "用户名不能为空")
+	}
+	tenant := ownerTenant(ctx, id)
+	before, _ := models.GetTenantUser(tenant, id) //只用来判断邮箱有没有换
+	u := models.User{TenantId: tenant}
+	after, err := u.Updatefields(id, int(req.GetVersion()), fields, auditEntry(ctx, models.AuditUserUpdate, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if after.Email != nil && after.GetEmail() != before.GetEmail() { //换了邮箱重新发验证邮件
+		if err := apis.SendVerificationMail(after); err != nil {
+			log.Println("send verification mail error", err)
+		}
+	}
+	return pb.NewUser(after), nil
+}
+
+// DeleteUser 删除用户，对应 DELETE /v1/users/:id
+func (s *UserServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*empty.Empty, error) {
+	id := int(req.GetId())
+	if err := requireOwnerOr(ctx, policy.PermUserDeleteAny, id); err != nil {
+		return nil, err
+	}
+	if req.GetVersion() == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "请带上 version，避免删除别人刚修改的数据")
+	}
+	tenant := ownerTenant(ctx, id)
+	u := models.User{Id: id, TenantId: tenant}
+	if _, err := u.Deleteuser(id, int(req.GetVersion()), auditEntry(ctx, models.AuditUserDelete, id)); err != nil {
+		return nil, notFound(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+// Login 用户名密码登录，签发和 POST /login 一样的token
+// 失败时不区分用户不存在、密码错误和账号锁定，耗时也补齐，避免被用来探测用户；新设备登录和 HTTP 一样发邮件提醒
+func (s *UserServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Token, error) {
+	org, err := models.FindOrganization(req.GetTenant())
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "组织不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	ip, userAgent := clientInfo(ctx)
+	client := security.Client{IP: ip, UserAgent: userAgent, Device: models.HashToken(userAgent)} //没有cookie，和 HTTP 一样按 User-Agent 认设备
+	failed := func(userId int, typ string, start time.Time) error {
+		security.RecordEvent(client, userId, req.GetName(), typ)
+		security.PadTiming(start)
+		return status.Error(codes.Unauthenticated, "用户名或密码错误")
+	}
+	start := time.Now()
+	login := models.User{Name: req.GetName(), Password: req.GetPassword(), TenantId: org.Id}
+	user, err := login.Login()
+	locked, _ := models.IsLocked(user.Id)
+	switch {
+	case locked && (err == nil || err == models.ErrWrongPassword):
+		return nil, failed(user.Id, models.EventAccountLocked, start)
+	case errors.Is(err, errs.ErrNotFound):
+		return nil, failed(0, models.EventUnknownUser, start)
+	case err == models.ErrWrongPassword:
+		return nil, failed(user.Id, models.EventWrongPassword, start)
+	case err != nil:
+		return nil, err
+	}
+	if blocked := user.LoginBlocked(); blocked != "" {
+		return nil, status.Error(codes.PermissionDenied, blocked)
+	}
+	security.RecordLoginSuccess(client, user, "")
+	role, err := models.MemberRole(user.TenantId, user.Id) //token里的角色是用户在当前组织里的角色
+	if errors.Is(err, models.ErrNotMember) {
+		role, err = user.Role, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session, err := user.CreateSession(req.GetDeviceName(), userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+	token, err := jwt.NewJWT().CreateToken(jwt.NewUserClaims(user, role, session.TokenId, TokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Token{User: pb.NewUser(user), Token: token}, nil
+}
+
+// ValidateToken 检查token是否有效，给其它服务校验用户带来的token
+func (s *UserServer) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.TokenInfo, error) {
+	claims, err := parseToken(ctx, req.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	info := &pb.TokenInfo{
+		UserId:         int64(claims.ID),
+		Name:           claims.Name,
+		Role:           int32(claims.Role),
+		TenantId:       int64(claims.Tenant),
+		EmailVerified:  claims.EmailVerified,
+		ImpersonatorId: int64(claims.ImpersonatorId),
+		SessionId:      claims.Id,
+	}
+	info.ExpiresAt, _ = ptypes.TimestampProto(time.Unix(claims.ExpiresAt, 0))
+	return info, nil
+}
+
+// ownerTenant 和 apis 一样：自己的账号按注册时所在的组织修改、删除，别人的只能是当前组织里注册的
+func ownerTenant(ctx context.Context, id int) int {
+	claims := claimsFrom(ctx)
+	if id == claims.ID {
+		if user, err := models.GetUser(id); err == nil {
+			return user.TenantId
+		}
+	}
+	return claims.Tenant
+}
+
+// notFound 记录不存在时换成具体的提示，其它错误交给 toStatus
+func notFound(err error) error {
+	if errors.Is(err, errs.ErrNotFound) {
+		return status.Error(codes.NotFound, "用户不存在")
+	}
+	return err
+}
+
+// auditEntry 按当前调用填好操作人、组织的审计日志，和 apis 的格式一样，修改前后的数据由 models 填
+func auditEntry(ctx context.Context, action string, targetId int) *models.AuditLog {
+	claims := claimsFrom(ctx)
+	entry := &models.AuditLog{
+		Action:     action,
+		TargetType: "user",
+		TargetId:   targetId,
+		RequestId:  getRequestID(ctx),
+		TenantId:   claims.Tenant,
+		ActorId:    claims.ID,
+		ActorName:  claims.Name,
+	}
+	if claims.ImpersonatorId != 0 { //模拟登录时记录真正的操作人
+		entry.ActorId = claims.ImpersonatorId
+		entry.ActorName = claims.ImpersonatorName + " as " + claims.Name
+	}
+	return entry
+}
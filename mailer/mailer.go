@@ -0,0 +1,120 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message 一封邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	SentAt  time.Time
+}
+
+// Mailer 发邮件的接口，生产用SMTP，开发和测试用文件或内存
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default 全局使用的mailer，默认写到本地文件，main里可以换成SMTP
+var Default Mailer = NewFileMailer("logs/outbox.log")
+
+// Send 用Default发送
+func Send(to, subject, body string) error {
+	return Default.Send(Message{To: to, Subject: subject, Body: body})
+}
+
+// SMTPMailer 通过SMTP服务器发送
+type SMTPMailer struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer 新建一个SMTP mailer
+func NewSMTPMailer(addr, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{Addr: addr, Username: username, Password: password, From: from}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := m.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		m.From, msg.To, msg.Subject, msg.Body)
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, []byte(body))
+}
+
+// FileMailer 把邮件追加写到文件里，开发环境用
+type FileMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFileMailer 新建一个写文件的mailer
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{Path: path}
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "=== %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
+
+// MemoryMailer 把邮件存在内存里，测试时可以直接读Outbox
+type MemoryMailer struct {
+	mu     sync.Mutex
+	outbox []Message
+}
+
+// NewMemoryMailer 新建一个内存mailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg.SentAt = time.Now()
+	m.outbox = append(m.outbox, msg)
+	return nil
+}
+
+// Outbox 返回已发送邮件的拷贝
+func (m *MemoryMailer) Outbox() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.outbox...)
+}
+
+// Last 返回最后一封发给to的邮件
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.outbox) - 1; i >= 0; i-- {
+		if m.outbox[i].To == to {
+			return m.outbox[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -1,14 +1,136 @@
 package main
 
 import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/xdtest/project/apis"
 	gorm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/grpcapi"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/deprecation"
+	"github.com/xdtest/project/middleware/policy"
 	model "github.com/xdtest/project/models"
+	"github.com/xdtest/project/password"
 	routers "github.com/xdtest/project/routers"
+	"github.com/xdtest/project/webhook"
 )
 
 func main() {
-	gorm.Eloquent.AutoMigrate(&model.User{}) //如果数据表结构发生变化自动更新mysql数据库结构
-	defer gorm.Eloquent.Close()              //关闭数据库链接
-	router := routers.InitRouter()           //指定路由
-	router.Run(":8000")                      //在8000端口上运行
+	// 审计日志hash链的HMAC密钥，不能和数据库放在一起，子命令 audit-verify 也要用
+	model.AuditKey = []byte(os.Getenv("AUDIT_HMAC_KEY"))
+	if len(model.AuditKey) == 0 {
+		log.Println("AUDIT_HMAC_KEY is not set, audit log hashes can be recomputed by anyone with database access")
+	}
+	if file := os.Getenv("AUDIT_CHECKPOINT_FILE"); file != "" {
+		model.AuditCheckpointFile = file
+	}
+	if len(os.Args) > 1 { //子命令，执行完直接退出
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	gorm.Eloquent.AutoMigrate( //如果数据表结构发生变化自动更新mysql数据库结构
+		&model.User{},
+		&model.PasswordReset{},
+		&model.EmailVerification{},
+		&model.MagicLink{},
+		&model.PasswordHistory{},
+		&model.Session{},
+		&model.SecurityEvent{},
+		&model.AuditLog{},
+		&model.Organization{},
+		&model.Membership{},
+		&model.Invitation{},
+		&model.UserEvent{},
+		&model.Webhook{},
+		&model.WebhookDelivery{},
+	)
+	if err := model.DropLegacyUserIndexes(); err != nil {
+		log.Println("drop legacy user indexes error", err)
+	}
+	if err := model.EnsureDefaultOrganization(); err != nil {
+		log.Println("create default organization error", err)
+	}
+	// 邮箱未验证时的策略：none, block_login, limited
+	if policy := os.Getenv("EMAIL_POLICY"); policy != "" {
+		model.EmailPolicy = policy
+	}
+	// 内部部署可以打开详细的登录、注册错误提示
+	apis.VerboseAuthErrors = os.Getenv("AUTH_VERBOSE_ERRORS") == "1"
+	// 注册模式：open, invite_only, closed, approval
+	if mode := os.Getenv("REGISTRATION_MODE"); mode != "" {
+		model.RegistrationMode = mode
+	}
+	// 泄露密码库的目录，按SHA1前5位分文件存放
+	if dir := os.Getenv("BREACHED_PASSWORD_DIR"); dir != "" {
+		password.Default.Breached = password.NewBreachedList(dir)
+	}
+	if err := password.Default.Breached.Check(); err != nil {
+		log.Println("breached password list unavailable, breached password check is disabled:", err)
+	}
+	// 每个用户最多同时登录的会话数，0表示不限制
+	if max, err := strconv.Atoi(os.Getenv("MAX_SESSIONS")); err == nil {
+		model.MaxSessions = max
+	}
+	// 策略文件，修改后自动重新加载；POLICY_DRY_RUN=1 时只记录拒绝不拦截
+	policyFile := os.Getenv("POLICY_FILE")
+	if policyFile == "" {
+		policyFile = "config/policies.yaml"
+	}
+	if err := policy.Default.Load(policyFile); err != nil {
+		log.Println("load policy error", err)
+	}
+	policy.Default.DryRun = os.Getenv("POLICY_DRY_RUN") == "1"
+	go policy.Default.Watch(5 * time.Second)
+	// 配置了SMTP就用真实邮件，否则写到logs/outbox.log
+	if host := os.Getenv("SMTP_ADDR"); host != "" {
+		mailer.Default = mailer.NewSMTPMailer(host, os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM"))
+	}
+	// 旧接口计划下线的日期，格式 2006-01-02
+	if sunset, err := time.Parse("2006-01-02", os.Getenv("LEGACY_SUNSET")); err == nil {
+		deprecation.Sunset = sunset
+	}
+	go purgeSecurityEvents()
+	go checkpointAuditLog()
+	go webhook.Default.Run() //发送用户事件的 webhook，失败的按间隔重试
+	// gRPC 和 HTTP 在同一个进程里，GRPC_ADDR=off 时不启动
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":9000"
+	}
+	if grpcAddr != "off" {
+		go func() {
+			if err := grpcapi.Serve(grpcAddr); err != nil {
+				log.Println("grpc serve error", err)
+			}
+		}()
+	}
+	defer gorm.Eloquent.Close()    //关闭数据库链接
+	router := routers.InitRouter() //指定路由
+	router.Run(":8000")            //在8000端口上运行
+}
+
+// purgeSecurityEvents 每天清理一次超过保留期的安全事件
+func purgeSecurityEvents() {
+	for {
+		if n, err := model.PurgeSecurityEvents(); err != nil {
+			log.Println("purge security events error", err)
+		} else if n > 0 {
+			log.Printf("purged %d security events", n)
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// checkpointAuditLog 每10分钟把审计日志最后一条的hash和条数记到库外的检查点文件
+func checkpointAuditLog() {
+	for {
+		if cp, err := model.WriteAuditCheckpoint(); err != nil {
+			log.Println("write audit checkpoint error", err)
+		} else if cp != nil {
+			log.Printf("audit checkpoint id=%d count=%d hash=%s", cp.Id, cp.Count, cp.Hash)
+		}
+		time.Sleep(10 * time.Minute)
+	}
 }
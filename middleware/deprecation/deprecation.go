@@ -0,0 +1,28 @@
+package deprecation
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/requestid"
+)
+
+// Sunset 旧接口计划下线的时间，为零时不返回 Sunset 头
+var Sunset = time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+
+// Deprecated 中间件，标记旧接口已废弃并指向替代的接口
+// 每次调用都打一条日志，用来统计还有哪些客户端没有迁移
+func Deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if !Sunset.IsZero() {
+			c.Header("Sunset", Sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		log.Printf("deprecated route request_id=%s method=%s path=%s successor=%q ip=%s user_agent=%q",
+			requestid.Get(c), c.Request.Method, c.Request.URL.Path, successor, c.ClientIP(), c.Request.UserAgent())
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package errhandler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/middleware/negotiate"
+	"github.com/xdtest/project/middleware/requestid"
+	"github.com/xdtest/project/pb"
+)
+
+// Error 带状态码和提示的错误，handler 用 c.Error 交给中间件统一响应
+type Error struct {
+	Status int
+	Msg    string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New 直接指定状态码和提示
+func New(status int, msg string) *Error {
+	return &Error{Status: status, Msg: msg}
+}
+
+// Wrap 状态码由 err 决定，客户端错误（4xx）时用 msg 作为提示
+// 例如查用户出错：不存在时返回 404 和 msg，数据库不可用时返回 503
+func Wrap(err error, msg string) *Error {
+	status, _ := Resolve(err)
+	return &Error{Status: status, Msg: msg, Err: err}
+}
+
+// Mapper 把错误映射成状态码和提示，不认识时 ok 为 false
+type Mapper func(err error) (status int, msg string, ok bool)
+
+var mappers = []Mapper{
+	Is(errs.ErrNotFound, http.StatusNotFound, "记录不存在"),
+	Is(errs.ErrConflict, http.StatusConflict, "数据已存在"),
+	Is(errs.ErrConstraint, http.StatusUnprocessableEntity, "数据不合法"),
+	Is(errs.ErrUnavailable, http.StatusServiceUnavailable, "服务暂时不可用，请稍后再试"),
+}
+
+// Is 匹配某个哨兵错误的 Mapper
+func Is(target error, status int, msg string) Mapper {
+	return func(err error) (int, string, bool) {
+		if errors.Is(err, target) {
+			return status, msg, true
+		}
+		return 0, "", false
+	}
+}
+
+// Register 注册额外的映射，优先于内置的
+func Register(m ...Mapper) {
+	mappers = append(append([]Mapper(nil), m...), mappers...)
+}
+
+// Resolve 错误对应的状态码和提示，都不认识时是 500
+func Resolve(err error) (int, string) {
+	var e *Error
+	if errors.As(err, &e) && e.Status != 0 {
+		if e.Status >= 500 {
+			return e.Status, http.StatusText(e.Status)
+		}
+		return e.Status, e.Msg
+	}
+	for _, m := range mappers {
+		if status, msg, ok := m(err); ok {
+			return status, msg
+		}
+	}
+	return http.StatusInternalServerError, "服务器内部错误"
+}
+
+// Handler 统一的错误处理中间件
+// handler 通过 c.Error 返回错误，这里按错误类型写响应；panic 也在这里恢复，不会让进程退出
+func Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic request_id=%s method=%s path=%s error=%q stack=%q",
+					requestid.Get(c), c.Request.Method, c.Request.URL.Path, fmt.Sprint(r), debug.Stack())
+				if !c.Writer.Written() {
+					respond(c, http.StatusInternalServerError, "服务器内部错误")
+				}
+				c.Abort()
+			}
+		}()
+		c.Next()
+		last := c.Errors.Last()
+		if last == nil {
+			return
+		}
+		status, msg := Resolve(last.Err)
+		if status >= 500 {
+			log.Printf("request error request_id=%s method=%s path=%s status=%d error=%q",
+				requestid.Get(c), c.Request.Method, c.Request.URL.Path, status, last.Err.Error())
+		}
+		if !c.Writer.Written() {
+			respond(c, status, msg)
+		}
+	}
+}
+
+// respond 按协商好的格式返回错误，没经过 negotiate 中间件的路由是JSON
+func respond(c *gin.Context, status int, msg string) {
+	negotiate.Render(c, status, gin.H{
+		"status":     -1,
+		"msg":        msg,
+		"request_id": requestid.Get(c),
+	}, &pb.Error{Status: -1, Msg: msg, RequestId: requestid.Get(c)})
+}
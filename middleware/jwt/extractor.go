@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenExtractor 从请求中取出token，取不到时返回空字符串
+type TokenExtractor func(c *gin.Context) (string, error)
+
+// 一些常量
+var (
+	TokenCSRFMismatch error  = errors.New("CSRF token mismatch")
+	TokenCookieName   string = "token"      // 存放jwt的HttpOnly cookie
+	CSRFCookieName    string = "csrf_token" // 存放csrf值的cookie，前端可读
+	CSRFHeaderName    string = "X-CSRF-Token"
+)
+
+// 默认的提取顺序：Bearer头 -> 旧的token头 -> cookie -> 表单字段jwt
+var tokenExtractors = []TokenExtractor{
+	BearerExtractor(),
+	HeaderExtractor("token"),
+	CookieExtractor(TokenCookieName),
+	FormExtractor("jwt"),
+}
+
+// SetTokenExtractors 替换token提取链，按顺序尝试，第一个取到的生效
+func SetTokenExtractors(extractors ...TokenExtractor) {
+	tokenExtractors = extractors
+}
+
+// ExtractToken 按提取链依次尝试取出token
+func ExtractToken(c *gin.Context) (string, error) {
+	for _, extract := range tokenExtractors {
+		token, err := extract(c)
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+	return "", nil
+}
+
+// BearerExtractor 读取 Authorization: Bearer <token>
+func BearerExtractor() TokenExtractor {
+	return func(c *gin.Context) (string, error) {
+		auth := c.Request.Header.Get("Authorization")
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:]), nil
+		}
+		return "", nil
+	}
+}
+
+// HeaderExtractor 读取指定请求头，兼容旧客户端的 token 头
+func HeaderExtractor(name string) TokenExtractor {
+	return func(c *gin.Context) (string, error) {
+		return c.Request.Header.Get(name), nil
+	}
+}
+
+// FormExtractor 读取表单字段，原来的 JwtMiddleWare 用的是 jwt 字段
+func FormExtractor(field string) TokenExtractor {
+	return func(c *gin.Context) (string, error) {
+		return c.PostForm(field), nil
+	}
+}
+
+// CookieExtractor 读取cookie中的token
+// cookie会被浏览器自动带上，所以非安全方法要做double-submit校验：
+// 请求头 X-CSRF-Token 必须和 csrf_token cookie 一致
+func CookieExtractor(name string) TokenExtractor {
+	return func(c *gin.Context) (string, error) {
+		token, err := c.Cookie(name)
+		if err != nil || token == "" {
+			return "", nil
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return token, nil
+		}
+		csrfCookie, _ := c.Cookie(CSRFCookieName)
+		csrfHeader := c.Request.Header.Get(CSRFHeaderName)
+		if csrfCookie == "" || subtle.ConstantTimeCompare([]byte(csrfCookie), []byte(csrfHeader)) != 1 {
+			return "", TokenCSRFMismatch
+		}
+		return token, nil
+	}
+}
+
+// SetTokenCookie 登录成功后把token写到HttpOnly cookie，同时下发csrf cookie
+func SetTokenCookie(c *gin.Context, token string, maxAge int) error {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	secure := c.Request.TLS != nil
+	c.SetCookie(TokenCookieName, token, maxAge, "/", "", secure, true)
+	c.SetCookie(CSRFCookieName, hex.EncodeToString(buf), maxAge, "/", "", secure, false)
+	return nil
+}
@@ -9,12 +9,22 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/requestid"
+	"github.com/xdtest/project/models"
 )
 
 // JWTAuth 中间件，检查token
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.Request.Header.Get("token")
+		token, err := ExtractToken(c)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status": -1,
+				"msg":    err.Error(),
+			})
+			c.Abort()
+			return
+		}
 		if token == "" {
 			c.JSON(http.StatusOK, gin.H{
 				"status": -1,
@@ -32,6 +42,15 @@ func JWTAuth() gin.HandlerFunc {
 		fmt.Println("claims", claims)
 		if err != nil {
 			if err == TokenExpired {
+				if claims != nil {
+					models.RecordSecurityEvent(&models.SecurityEvent{
+						UserId:    claims.ID,
+						Account:   claims.Name,
+						Type:      models.EventTokenExpired,
+						IP:        c.ClientIP(),
+						UserAgent: c.Request.UserAgent(),
+					})
+				}
 				c.JSON(http.StatusOK, gin.H{
 					"status": -1,
 					"msg":    "授权已过期",
@@ -46,8 +65,104 @@ func JWTAuth() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// 会话被吊销（退出、在其它设备被踢掉）的token不能再用
+		session, err := models.FindActiveSession(claims.Id)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status": -1,
+				"msg":    "会话已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+		if claims.ImpersonatorId != 0 && !impersonationAllowed(c, claims) {
+			return
+		}
 		// 继续交由下一个路由处理,并将解析出的信息传递下去
 		c.Set("claims", claims)
+		c.Set("session", session)
+	}
+}
+
+// ImpersonationAllowlist 模拟登录时允许调用的接口，格式为 "方法 路由"，只放只读的接口
+// 没有列出的一律拒绝，新加的接口默认不能在模拟登录时调用
+var ImpersonationAllowlist = map[string]bool{
+	"GET /user_list_new_handler": true,
+	"POST /graphql":              true, //mutation 由 apis.GraphqlImpersonationAllowlist 控制
+	"GET /v1/userinfo":           true,
+	"GET /v1/orgs":               true,
+	"GET /v1/sessions":           true,
+	"GET /v1/me/security-events": true,
+	"POST /v1/test":              true,
+	"GET /v1/users":              true,
+	"GET /v1/users/:id":          true,
+	"GET /v1/me":                 true,
+}
+
+// impersonationAllowed 模拟登录的请求：响应头加标记、写审计日志、拦截不在白名单里的接口
+// 审计日志写不进去时也拒绝，不能留下没有记录的操作
+func impersonationAllowed(c *gin.Context, claims *CustomClaims) bool {
+	c.Header("X-Impersonated-By", claims.ImpersonatorName)
+	route := c.Request.Method + " " + c.FullPath()
+	blocked := !ImpersonationAllowlist[route]
+	entry := models.AuditLog{
+		TenantId:   claims.Tenant,
+		ActorId:    claims.ImpersonatorId,
+		ActorName:  claims.ImpersonatorName + " as " + claims.Name,
+		Action:     models.AuditImpersonateAction,
+		TargetType: "user",
+		TargetId:   claims.ID,
+		RequestId:  requestid.Get(c),
+	}
+	if err := models.AppendAudit(&entry, nil, gin.H{"route": route, "blocked": blocked}); err != nil {
+		log.Println("append impersonation audit log error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": -1,
+			"msg":    "审计日志写入失败，请稍后重试",
+		})
+		c.Abort()
+		return false
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status": -1,
+			"msg":    "模拟登录时不允许该操作",
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// RequireVerifiedEmail 邮箱未验证的用户不能访问，enabled为false时直接放行
+// 需要放在JWTAuth之后
+func RequireVerifiedEmail(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			return
+		}
+		claims, ok := c.Get("claims")
+		if !ok || !claims.(*CustomClaims).EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status": -1,
+				"msg":    "邮箱未验证，无权限访问",
+			})
+			c.Abort()
+		}
+	}
+}
+
+// RequireAdmin 只允许管理员访问，需要放在JWTAuth之后
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.Get("claims")
+		if !ok || claims.(*CustomClaims).Role != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status": -1,
+				"msg":    "需要管理员权限",
+			})
+			c.Abort()
+		}
 	}
 }
 
@@ -66,13 +181,37 @@ var (
 )
 
 // 载荷，可以加一些自己需要的信息
+// token 只是签名没有加密，任何人都能解出内容，不要放密码这类敏感信息
 type CustomClaims struct {
-	ID       int    `json:"userId"`
-	Name     string `json:"name"`
-	Password string `json:"telephone"`
+	ID   int    `json:"userId"`
+	Name string `json:"name"`
+
+	EmailVerified bool `json:"email_verified"`
+	Role          int  `json:"role"`
+	Tenant        int  `json:"tenant"` //当前所在的组织
+
+	ImpersonatorId   int    `json:"impersonator_id,omitempty"` //管理员模拟登录时，真正的操作人
+	ImpersonatorName string `json:"impersonator_name,omitempty"`
 	jwt.StandardClaims
 }
 
+// NewUserClaims 用户登录后的token内容，role 是用户在当前组织里的角色，tokenId 对应会话，ttl 为有效秒数
+func NewUserClaims(user models.User, role int, tokenId string, ttl int64) CustomClaims {
+	return CustomClaims{
+		ID:            user.Id,
+		Name:          user.Name,
+		EmailVerified: user.EmailVerified(),
+		Role:          role,
+		Tenant:        user.TenantId,
+		StandardClaims: jwt.StandardClaims{
+			NotBefore: int64(time.Now().Unix() - 1000), // 签名生效时间
+			ExpiresAt: int64(time.Now().Unix() + ttl),  // 过期时间
+			Issuer:    "newtrekWang",                   //签名的发行者
+			Id:        tokenId,                         //对应的会话
+		},
+	}
+}
+
 // 新建一个jwt实例
 func NewJWT() *JWT {
 	return &JWT{
@@ -107,7 +246,10 @@ func (j *JWT) ParseToken(tokenString string) (*CustomClaims, error) {
 			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
 				return nil, TokenMalformed
 			} else if ve.Errors&jwt.ValidationErrorExpired != 0 {
-				// Token is expired
+				// Token is expired，签名没问题时把claims也返回，方便记录是谁的token过期了
+				if claims, ok := token.Claims.(*CustomClaims); ok && ve.Errors == jwt.ValidationErrorExpired {
+					return claims, TokenExpired
+				}
 				return nil, TokenExpired
 			} else if ve.Errors&jwt.ValidationErrorNotValidYet != 0 {
 				return nil, TokenNotValidYet
@@ -135,6 +277,9 @@ func (j *JWT) RefreshToken(tokenString string) (string, error) {
 	}
 	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
 		jwt.TimeFunc = time.Now
+		if claims.ImpersonatorId != 0 { //模拟登录的token不能续期
+			return "", TokenInvalid
+		}
 		claims.StandardClaims.ExpiresAt = time.Now().Add(1 * time.Hour).Unix()
 		return j.CreateToken(*claims)
 	}
@@ -0,0 +1,256 @@
+package negotiate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/proto"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
+)
+
+// 支持的格式
+const (
+	JSON     = "application/json"
+	MsgPack  = "application/msgpack"
+	YAML     = "application/yaml"
+	Protobuf = "application/x-protobuf"
+)
+
+// Offered 支持的响应格式，Accept 里优先级相同时按这个顺序选
+var Offered = []string{JSON, MsgPack, YAML, Protobuf}
+
+// aliases 同一种格式常见的其它写法
+var aliases = map[string]string{
+	"application/x-msgpack":           MsgPack,
+	"application/vnd.msgpack":         MsgPack,
+	"application/x-yaml":              YAML,
+	"text/yaml":                       YAML,
+	"text/x-yaml":                     YAML,
+	"application/protobuf":            Protobuf,
+	"application/vnd.google.protobuf": Protobuf,
+}
+
+var (
+	ErrNotAcceptable        = errors.New("no acceptable content type")
+	ErrUnsupportedMediaType = errors.New("unsupported content type")
+	ErrRequestTooLarge      = errors.New("request body too large")
+)
+
+// MaxBodySize Decode 最多读取的请求体字节数，超过时返回 ErrRequestTooLarge
+var MaxBodySize int64 = 1 << 20
+
+const contextKey = "negotiate:format"
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true //字符串解码成 string 而不是 []byte
+	return h
+}()
+
+// Canonical 把 Content-Type 或 Accept 里的类型转成上面的常量，不支持时返回空字符串
+func Canonical(mediaType string) string {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if i := strings.IndexByte(mediaType, ';'); i >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:i])
+	}
+	if alias, ok := aliases[mediaType]; ok {
+		return alias
+	}
+	for _, f := range Offered {
+		if f == mediaType {
+			return f
+		}
+	}
+	return ""
+}
+
+// Accept 按 Accept 请求头选出响应格式，没有可用的格式时返回空字符串
+// 没有 Accept 头时用JSON；q 值高的优先，相同时按 Accept 里的顺序，再按 Offered 的顺序
+func Accept(header string) string {
+	if strings.TrimSpace(header) == "" {
+		return JSON
+	}
+	best, bestQ, bestPos := "", 0.0, 0
+	for _, f := range Offered {
+		q, pos := quality(header, f)
+		if q > bestQ || (q == bestQ && q > 0 && pos < bestPos) {
+			best, bestQ, bestPos = f, q, pos
+		}
+	}
+	return best
+}
+
+// quality 格式在 Accept 里的 q 值和位置，具体的类型优先于 type/* 和 */*
+func quality(header, format string) (q float64, pos int) {
+	specificity := -1
+	for i, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case Canonical(mediaType) == format:
+			s = 2
+		case mediaType == strings.SplitN(format, "/", 2)[0]+"/*":
+			s = 1
+		case mediaType == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q, pos = s, 1, i
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return q, pos
+}
+
+// Negotiate 中间件，按 Accept 选好响应格式；客户端要的格式都不支持时返回406
+func Negotiate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := Accept(c.GetHeader("Accept"))
+		if format == "" {
+			c.Error(ErrNotAcceptable)
+			c.Abort()
+			return
+		}
+		c.Set(contextKey, format)
+		c.Header("Vary", "Accept")
+		c.Next()
+	}
+}
+
+// Format 当前请求的响应格式，没经过 Negotiate 的路由是JSON
+func Format(c *gin.Context) string {
+	if f := c.GetString(contextKey); f != "" {
+		return f
+	}
+	return JSON
+}
+
+// Render 按协商好的格式写响应
+// obj 是JSON、MessagePack、YAML 共用的内容，msg 是 Protobuf 的内容，msg 为nil时 Protobuf 只返回状态码
+func Render(c *gin.Context, status int, obj interface{}, msg proto.Message) {
+	switch Format(c) {
+	case Protobuf:
+		if msg == nil {
+			c.Status(status)
+			return
+		}
+		c.ProtoBuf(status, msg)
+	case MsgPack:
+		var buf []byte
+		v, err := plain(obj)
+		if err == nil {
+			err = codec.NewEncoderBytes(&buf, msgpackHandle).Encode(v)
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.Data(status, MsgPack, buf)
+	case YAML:
+		v, err := plain(obj)
+		var data []byte
+		if err == nil {
+			data, err = yaml.Marshal(v)
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.Data(status, YAML+"; charset=utf-8", data)
+	default:
+		c.JSON(status, obj)
+	}
+}
+
+// plain 先按JSON编码再解出来，让 MessagePack 和 YAML 用和JSON一样的字段名
+func plain(obj interface{}) (interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return numbers(v), nil
+}
+
+// numbers 把 json.Number 换成整数或浮点数
+func numbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = numbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = numbers(e)
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
+
+// ContentFormat 请求体的格式，没有 Content-Type 时按JSON处理，不支持时返回空字符串
+func ContentFormat(c *gin.Context) string {
+	ct := c.GetHeader("Content-Type")
+	if ct == "" {
+		return JSON
+	}
+	if Canonical(ct) == "" && strings.HasSuffix(strings.SplitN(ct, ";", 2)[0], "+json") { //如 application/merge-patch+json
+		return JSON
+	}
+	return Canonical(ct)
+}
+
+// Decode 按 Content-Type 读取请求体
+// Protobuf 时 v 必须是 proto.Message；不支持的格式返回 ErrUnsupportedMediaType，超过 MaxBodySize 返回 ErrRequestTooLarge
+func Decode(c *gin.Context, v interface{}) error {
+	format := ContentFormat(c)
+	if format == "" {
+		return ErrUnsupportedMediaType
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, MaxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > MaxBodySize {
+		return ErrRequestTooLarge
+	}
+	switch format {
+	case Protobuf:
+		msg, ok := v.(proto.Message)
+		if !ok {
+			return ErrUnsupportedMediaType
+		}
+		return proto.Unmarshal(data, msg)
+	case MsgPack:
+		return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+	case YAML:
+		return yaml.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,121 @@
+package negotiate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccept(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"", JSON},
+		{"application/json", JSON},
+		{"application/x-protobuf", Protobuf},
+		{"application/x-msgpack", MsgPack}, // 别名
+		{"text/yaml; charset=utf-8", YAML},
+		{"*/*", JSON},           // 都能用时按 Offered 的顺序
+		{"application/*", JSON}, // 同上
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+		{"application/yaml;q=0.5, application/msgpack", MsgPack},       // q 值高的优先
+		{"application/yaml, application/msgpack", YAML},                // q 相同按 Accept 里的顺序
+		{"application/msgpack;q=0.9, application/yaml;q=0.9", MsgPack}, // 同上
+		{"application/json;q=0, */*", MsgPack},                         // 具体的类型优先于 */*
+		{"*/*;q=0.1, application/x-protobuf;q=0.2", Protobuf},          // 同上
+		{"application/json;q=abc", JSON},                               // q 写错了按1算
+		{"text/html, application/xhtml+xml, */*;q=0.8", JSON},          // 浏览器的 Accept
+		{"application/vnd.google.protobuf, application/json;q=0.5", Protobuf},
+	}
+	for _, tt := range tests {
+		if got := Accept(tt.header); got != tt.want {
+			t.Errorf("Accept(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestQuality(t *testing.T) {
+	tests := []struct {
+		header, format string
+		q              float64
+		pos            int
+	}{
+		{"application/json", JSON, 1, 0},
+		{"text/html", JSON, 0, 0},
+		{"application/*;q=0.3, application/yaml;q=0.8", YAML, 0.8, 1},
+		{"application/*;q=0.3, application/yaml;q=0.8", JSON, 0.3, 0},
+		{"application/yaml;q=0.8, */*;q=0.1", YAML, 0.8, 0}, // 后面不那么具体的不会覆盖前面的
+		{"*/*;q=0.1, text/html, application/msgpack;q=0.4", MsgPack, 0.4, 2},
+		{"bad;;type, application/json;q=0.7", JSON, 0.7, 1}, // 解析不了的跳过
+	}
+	for _, tt := range tests {
+		q, pos := quality(tt.header, tt.format)
+		if q != tt.q || pos != tt.pos {
+			t.Errorf("quality(%q, %q) = %v, %d, want %v, %d", tt.header, tt.format, q, pos, tt.q, tt.pos)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		accept string
+		status int
+		format string
+	}{
+		{"", http.StatusOK, JSON},
+		{"application/x-protobuf", http.StatusOK, Protobuf},
+		{"text/html", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Next()
+			if len(c.Errors) > 0 && c.Errors.Last().Err == ErrNotAcceptable {
+				c.Status(http.StatusNotAcceptable)
+			}
+		})
+		var format string
+		r.GET("/", Negotiate(), func(c *gin.Context) {
+			format = Format(c)
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status || format != tt.format {
+			t.Errorf("Accept %q: status %d, format %q, want %d, %q", tt.accept, w.Code, format, tt.status, tt.format)
+		}
+		if tt.status == http.StatusOK && w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(n int64) { MaxBodySize = n }(MaxBodySize)
+	MaxBodySize = 16
+	tests := []struct {
+		body string
+		want error
+	}{
+		{`{"name":"alice"}`, nil}, // 正好16字节
+		{`{"name":"alice2"}`, ErrRequestTooLarge},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", JSON)
+		var v map[string]interface{}
+		if err := Decode(c, &v); err != tt.want {
+			t.Errorf("Decode(%s) = %v, want %v", tt.body, err, tt.want)
+		}
+	}
+}
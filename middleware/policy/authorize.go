@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/models"
+)
+
+// ResourceFunc 从请求中取出被操作资源的属性
+type ResourceFunc func(c *gin.Context) (Attributes, error)
+
+// SubjectFromClaims token里的信息作为主体属性
+func SubjectFromClaims(claims *jwt.CustomClaims) Attributes {
+	return Attributes{
+		"id":             claims.ID,
+		"name":           claims.Name,
+		"role":           models.RoleNames[claims.Role],
+		"role_id":        claims.Role,
+		"email_verified": claims.EmailVerified,
+		"tenant":         claims.Tenant,
+	}
+}
+
+// RequestContext 请求上下文属性：时间、IP、方法、路径
+func RequestContext(c *gin.Context) Attributes {
+	now := time.Now()
+	return Attributes{
+		"time":    now.Format(time.RFC3339),
+		"hour":    now.Hour(),
+		"weekday": int(now.Weekday()),
+		"ip":      c.ClientIP(),
+		"method":  c.Request.Method,
+		"path":    c.FullPath(),
+	}
+}
+
+// UserResource 被操作的是用户，从数据库加载它的属性
+func UserResource(target TargetFunc) ResourceFunc {
+	return func(c *gin.Context) (Attributes, error) {
+		user, err := models.GetUser(target(c))
+		if err != nil {
+			return nil, err
+		}
+		return Attributes{
+			"type":     "user",
+			"id":       user.Id,
+			"owner_id": user.Id,
+			"role":     models.RoleNames[user.Role],
+			"tenant":   user.TenantId,
+		}, nil
+	}
+}
+
+// Authorize 中间件，用默认引擎判断当前用户能否执行action，需要放在JWTAuth之后
+// 被拒绝时把每条规则的匹配过程写到日志，DryRun时只记录不拦截
+// 资源不存在时也先鉴权，没有权限的返回403，避免用404探测资源是否存在
+func Authorize(action string, resource ResourceFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("claims").(*jwt.CustomClaims)
+		res, err := resource(c)
+		missing := errors.Is(err, errs.ErrNotFound)
+		if err != nil && !missing {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if missing { //没有资源的属性，依赖资源属性的规则都不会命中
+			res = Attributes{}
+		}
+		req := Request{
+			Subject:  SubjectFromClaims(claims),
+			Action:   action,
+			Resource: res,
+			Context:  RequestContext(c),
+		}
+		decision := Default.Evaluate(req)
+		if !decision.Allowed {
+			trace, _ := json.Marshal(decision)
+			log.Printf("policy denied action=%s subject=%d dry_run=%v decision=%s", action, claims.ID, Default.DryRun, trace)
+			if !Default.DryRun {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status": -1,
+					"msg":    "无权限执行该操作",
+				})
+				c.Abort()
+				return
+			}
+		}
+		if missing {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status": -1,
+				"msg":    "资源不存在",
+			})
+			c.Abort()
+		}
+	}
+}
+
+// Explain 管理员调试用，提交一个鉴权请求，返回结果和每条规则的匹配过程
+func Explain(c *gin.Context) {
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": -1,
+			"msg":    err.Error(),
+		})
+		return
+	}
+	if req.Context == nil {
+		req.Context = RequestContext(c)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": 0,
+		"data":   Default.Evaluate(req),
+	})
+}
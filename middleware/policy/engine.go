@@ -0,0 +1,304 @@
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Attributes 主体、资源、请求上下文的属性
+type Attributes map[string]interface{}
+
+// Condition 规则里的一个条件，Attr 形如 subject.role、resource.tenant、context.hour
+// 和固定值比较用 Value，和另一个属性比较用 ValueFrom
+type Condition struct {
+	Attr      string      `yaml:"attr" json:"attr"`
+	Op        string      `yaml:"op" json:"op"` // eq, ne, in, not_in, gt, gte, lt, lte, between, exists
+	Value     interface{} `yaml:"value" json:"value,omitempty"`
+	ValueFrom string      `yaml:"value_from" json:"value_from,omitempty"`
+}
+
+// Rule 一条策略规则，所有条件都满足时生效
+type Rule struct {
+	Name    string      `yaml:"name" json:"name"`
+	Effect  string      `yaml:"effect" json:"effect"`   // allow 或 deny
+	Actions []string    `yaml:"actions" json:"actions"` // 支持 * 和 user:* 这样的前缀匹配
+	When    []Condition `yaml:"when" json:"when"`
+}
+
+// Request 一次鉴权请求
+type Request struct {
+	Subject  Attributes `json:"subject"`
+	Action   string     `json:"action"`
+	Resource Attributes `json:"resource"`
+	Context  Attributes `json:"context"`
+}
+
+// RuleTrace 每条规则的匹配过程，用来排查为什么被拒绝
+type RuleTrace struct {
+	Rule    string `json:"rule"`
+	Effect  string `json:"effect"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Decision 鉴权结果，任意deny规则命中即拒绝，否则至少一条allow命中才允许
+type Decision struct {
+	Allowed bool        `json:"allowed"`
+	Rule    string      `json:"rule,omitempty"`
+	Trace   []RuleTrace `json:"trace"`
+}
+
+// Engine 策略引擎，规则从YAML文件加载
+type Engine struct {
+	DryRun bool // 只记录拒绝不拦截，用来上线新策略前观察
+
+	mu      sync.RWMutex
+	rules   []Rule
+	path    string
+	modTime time.Time
+}
+
+// Default 全局的策略引擎
+var Default = &Engine{}
+
+// policyFile YAML文件的结构
+type policyFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Load 从YAML文件加载规则，出错时保留原来的规则
+func (e *Engine) Load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file policyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	for i, r := range file.Rules {
+		if r.Effect != "allow" && r.Effect != "deny" {
+			return fmt.Errorf("rule %d (%s): effect must be allow or deny", i, r.Name)
+		}
+	}
+	e.mu.Lock()
+	e.rules = file.Rules
+	e.path = path
+	e.modTime = info.ModTime()
+	e.mu.Unlock()
+	return nil
+}
+
+// Watch 定时检查文件修改时间，变化了就重新加载
+func (e *Engine) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		e.mu.RLock()
+		path, modTime := e.path, e.modTime
+		e.mu.RUnlock()
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().After(modTime) {
+			continue
+		}
+		if err := e.Load(path); err != nil {
+			log.Println("reload policy error", err)
+		} else {
+			log.Println("policy reloaded from", path)
+		}
+	}
+}
+
+// Rules 当前生效的规则
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// Evaluate 对请求做鉴权，并返回每条规则的匹配过程
+func (e *Engine) Evaluate(req Request) Decision {
+	var d Decision
+	allowRule := ""
+	for _, r := range e.Rules() {
+		t := RuleTrace{Rule: r.Name, Effect: r.Effect}
+		if !matchAction(r.Actions, req.Action) {
+			t.Reason = "action not matched"
+		} else if reason := req.failedCondition(r.When); reason != "" {
+			t.Reason = reason
+		} else {
+			t.Matched = true
+		}
+		d.Trace = append(d.Trace, t)
+		if !t.Matched {
+			continue
+		}
+		if r.Effect == "deny" {
+			d.Allowed = false
+			d.Rule = r.Name
+			return d
+		}
+		if allowRule == "" {
+			allowRule = r.Name
+		}
+	}
+	d.Allowed = allowRule != ""
+	d.Rule = allowRule
+	return d
+}
+
+// Can 用默认引擎判断 subject 能否对 resource 执行 action
+func Can(subject Attributes, action string, resource Attributes) bool {
+	return Default.Evaluate(Request{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+		Context:  NowContext(),
+	}).Allowed
+}
+
+// NowContext 只包含时间的请求上下文
+func NowContext() Attributes {
+	now := time.Now()
+	return Attributes{
+		"time":    now.Format(time.RFC3339),
+		"hour":    now.Hour(),
+		"weekday": int(now.Weekday()),
+	}
+}
+
+func matchAction(patterns []string, action string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == action {
+			return true
+		}
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(action, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup 取属性，name 形如 subject.tenant
+func (req Request) lookup(name string) (interface{}, bool) {
+	i := strings.IndexByte(name, '.')
+	if i < 0 {
+		return nil, false
+	}
+	var attrs Attributes
+	switch name[:i] {
+	case "subject":
+		attrs = req.Subject
+	case "resource":
+		attrs = req.Resource
+	case "context":
+		attrs = req.Context
+	}
+	v, ok := attrs[name[i+1:]]
+	return v, ok && v != nil
+}
+
+// failedCondition 返回第一个不满足的条件，全部满足返回空字符串
+func (req Request) failedCondition(conds []Condition) string {
+	for _, cond := range conds {
+		actual, ok := req.lookup(cond.Attr)
+		if cond.Op == "exists" {
+			if !ok {
+				return cond.Attr + " not exists"
+			}
+			continue
+		}
+		if !ok {
+			return cond.Attr + " is missing"
+		}
+		expected := cond.Value
+		if cond.ValueFrom != "" {
+			if expected, ok = req.lookup(cond.ValueFrom); !ok {
+				return cond.ValueFrom + " is missing"
+			}
+		}
+		if !compare(cond.Op, actual, expected) {
+			return fmt.Sprintf("%s %s %v failed (actual %v)", cond.Attr, cond.Op, expected, actual)
+		}
+	}
+	return ""
+}
+
+func compare(op string, actual, expected interface{}) bool {
+	switch op {
+	case "eq":
+		return equal(actual, expected)
+	case "ne":
+		return !equal(actual, expected)
+	case "in", "not_in":
+		found := false
+		if list, ok := expected.([]interface{}); ok {
+			for _, v := range list {
+				if equal(actual, v) {
+					found = true
+					break
+				}
+			}
+		}
+		return found == (op == "in")
+	case "gt", "gte", "lt", "lte":
+		a, ok1 := toFloat(actual)
+		b, ok2 := toFloat(expected)
+		if !ok1 || !ok2 {
+			return false
+		}
+		switch op {
+		case "gt":
+			return a > b
+		case "gte":
+			return a >= b
+		case "lt":
+			return a < b
+		default:
+			return a <= b
+		}
+	case "between": // 左闭右开，如 hour between [9, 18]
+		list, ok := expected.([]interface{})
+		if !ok || len(list) != 2 {
+			return false
+		}
+		a, ok1 := toFloat(actual)
+		lo, ok2 := toFloat(list[0])
+		hi, ok3 := toFloat(list[1])
+		return ok1 && ok2 && ok3 && a >= lo && a < hi
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/jwt"
+)
+
+// 权限，也是策略文件里的 action，带 any 的表示可以操作别人的数据
+// 哪些角色有哪些权限写在策略文件里
+const (
+	PermUserCreateAny = "user:create:any"
+	PermUserReadAny   = "user:read:any"
+	PermUserUpdateAny = "user:update:any"
+	PermUserDeleteAny = "user:delete:any"
+	// 别人的登录设备和安全事件
+	PermSessionReadAny       = "session:read:any"
+	PermSecurityEventReadAny = "security_event:read:any"
+)
+
+// Allowed 用默认引擎判断能否对当前组织里的用户 targetId 执行 perm，不受 DryRun 影响
+// 能不能操作自己的数据也由策略文件决定；targetId 为0表示没有具体目标，如列表、新建
+func Allowed(claims *jwt.CustomClaims, perm string, targetId int) bool {
+	resource := Attributes{
+		"type":   strings.SplitN(perm, ":", 2)[0],
+		"tenant": claims.Tenant,
+	}
+	if targetId != 0 {
+		resource["id"] = targetId
+		resource["owner_id"] = targetId
+	}
+	return Can(SubjectFromClaims(claims), perm, resource)
+}
+
+// TargetFunc 从请求中取出要操作的用户id
+type TargetFunc func(c *gin.Context) int
+
+// QueryID 从查询参数取id，如 /updatauser?id=1
+func QueryID(name string) TargetFunc {
+	return func(c *gin.Context) int {
+		id, _ := strconv.Atoi(c.Query(name))
+		return id
+	}
+}
+
+// ParamID 从路径参数取id，如 /users/:id
+func ParamID(name string) TargetFunc {
+	return func(c *gin.Context) int {
+		id, _ := strconv.Atoi(c.Param(name))
+		return id
+	}
+}
+
+// RequireOwnerOr 中间件，只能操作自己的数据，拥有perm权限的可以操作任何人的，规则见 Allowed
+// 需要放在JWTAuth之后
+func RequireOwnerOr(perm string, target TargetFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("claims")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status": -1,
+				"msg":    "请求未携带token，无权限访问",
+			})
+			c.Abort()
+			return
+		}
+		claims := v.(*jwt.CustomClaims)
+		if !Allowed(claims, perm, target(c)) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status": -1,
+				"msg":    "无权限操作该用户",
+			})
+			c.Abort()
+		}
+	}
+}
+
+// RequirePermission 中间件，必须拥有perm权限，用于列表、新建这类没有具体目标用户的操作
+// 需要放在JWTAuth之后
+func RequirePermission(perm string) gin.HandlerFunc {
+	return RequireOwnerOr(perm, func(c *gin.Context) int { return 0 })
+}
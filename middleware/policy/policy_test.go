@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/models"
+)
+
+func loadDefault(t *testing.T) {
+	if err := Default.Load("../../config/policies.yaml"); err != nil {
+		t.Fatalf("load policies.yaml: %v", err)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	loadDefault(t)
+	admin := &jwt.CustomClaims{ID: 1, Role: models.RoleAdmin, Tenant: 1}
+	user := &jwt.CustomClaims{ID: 2, Role: models.RoleUser, Tenant: 1}
+	support := &jwt.CustomClaims{ID: 3, Role: models.RoleSupport, Tenant: 1}
+	tests := []struct {
+		name   string
+		claims *jwt.CustomClaims
+		perm   string
+		target int
+		want   bool
+	}{
+		{"admin lists users", admin, PermUserReadAny, 0, true},
+		{"admin deletes another user", admin, PermUserDeleteAny, 2, true},
+		{"admin reads sessions", admin, PermSessionReadAny, 2, true},
+		{"user lists users", user, PermUserReadAny, 0, false},
+		{"user reads self", user, PermUserReadAny, 2, true},
+		{"user updates another user", user, PermUserUpdateAny, 1, false},
+		{"user reads own sessions", user, PermSessionReadAny, 2, true},
+		{"user reads another user's security events", user, PermSecurityEventReadAny, 1, false},
+		{"user creates users", user, PermUserCreateAny, 0, false},
+		{"support lists users", support, PermUserReadAny, 0, false},
+	}
+	for _, tt := range tests {
+		if got := Allowed(tt.claims, tt.perm, tt.target); got != tt.want {
+			t.Errorf("%s: Allowed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedWithoutRules(t *testing.T) {
+	e := Default
+	defer func() { Default = e }()
+	Default = &Engine{}
+	admin := &jwt.CustomClaims{ID: 1, Role: models.RoleAdmin, Tenant: 1}
+	if Allowed(admin, PermUserReadAny, 0) {
+		t.Error("Allowed without any rule loaded, want denied")
+	}
+}
+
+// 资源不存在时，没有权限的人应该得到403而不是404
+func TestAuthorizeMissingResource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	loadDefault(t)
+	missing := func(c *gin.Context) (Attributes, error) {
+		return nil, &errs.Error{Kind: errs.ErrNotFound, Err: errs.ErrNotFound}
+	}
+	tests := []struct {
+		name   string
+		claims *jwt.CustomClaims
+		action string
+		want   int
+	}{
+		{"user", &jwt.CustomClaims{ID: 2, Role: models.RoleUser, Tenant: 1}, "user:reset_password", http.StatusForbidden},
+		{"admin", &jwt.CustomClaims{ID: 1, Role: models.RoleAdmin, Tenant: 1}, "user:reset_password", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		router := gin.New()
+		router.GET("/users/:id", func(c *gin.Context) { c.Set("claims", tt.claims) }, Authorize(tt.action, missing), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/99", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	// 有不依赖资源属性的规则放行时才返回404
+	allowAll := &Engine{rules: []Rule{{Name: "all", Effect: "allow", Actions: []string{"*"}}}}
+	e := Default
+	defer func() { Default = e }()
+	Default = allowAll
+	router := gin.New()
+	router.GET("/users/:id", func(c *gin.Context) { c.Set("claims", &jwt.CustomClaims{ID: 2}) }, Authorize("user:reset_password", missing), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/99", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("allowed missing resource: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,31 @@
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header 请求ID使用的请求头/响应头
+const Header = "X-Request-ID"
+
+// RequestID 中间件，给每个请求分配一个ID，客户端带了就沿用
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Request.Header.Get(Header)
+		if id == "" || len(id) > 64 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set("request_id", id)
+		c.Header(Header, id)
+		c.Next()
+	}
+}
+
+// Get 取出当前请求的ID
+func Get(c *gin.Context) string {
+	return c.GetString("request_id")
+}
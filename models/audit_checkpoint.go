@@ -0,0 +1,85 @@
+package models
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// AuditCheckpointFile 审计日志检查点文件，每行一个检查点，只追加
+// 要放在数据库以外的地方，最好是只能追加的存储，这样删掉末尾的审计日志也能发现
+var AuditCheckpointFile = "logs/audit_checkpoint.log"
+
+// AuditCheckpoint 某个时刻审计日志的最后一条和总条数
+type AuditCheckpoint struct {
+	Time  time.Time `json:"time"`
+	Id    int       `json:"id"`
+	Count int       `json:"count"`
+	Hash  string    `json:"hash"`
+}
+
+// WriteAuditCheckpoint 记录当前最后一条审计日志的 Hash 和总条数，返回写入的检查点
+// 没有审计日志或者和上一个检查点一样时不写，返回nil
+func WriteAuditCheckpoint() (cp *AuditCheckpoint, err error) {
+	defer translate(&err)
+	var last AuditLog
+	if err = orm.Eloquent.Order("id desc").First(&last).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) { //还没有审计日志
+			err = nil
+		}
+		return
+	}
+	cp = &AuditCheckpoint{Time: time.Now(), Id: last.Id, Hash: last.Hash}
+	if err = orm.Eloquent.Model(&AuditLog{}).Where("id <= ?", last.Id).Count(&cp.Count).Error; err != nil {
+		return nil, err
+	}
+	prev, err := LastAuditCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && prev.Id == cp.Id && prev.Hash == cp.Hash {
+		return nil, nil
+	}
+	f, err := os.OpenFile(AuditCheckpointFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, _ := json.Marshal(cp)
+	_, err = f.Write(append(data, '\n'))
+	return
+}
+
+// LastAuditCheckpoint 最近一次的检查点，还没有检查点时返回nil
+func LastAuditCheckpoint() (*AuditCheckpoint, error) {
+	f, err := os.Open(AuditCheckpointFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return nil, nil
+	}
+	var cp AuditCheckpoint
+	if err := json.Unmarshal(last, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
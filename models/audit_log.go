@@ -0,0 +1,321 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// 审计动作
+const (
+	AuditUserCreate        = "user.create"
+	AuditUserUpdate        = "user.update"
+	AuditUserDelete        = "user.delete"
+	AuditUserPasswordReset = "user.password_reset"
+	AuditMemberRole        = "member.role" //加入组织或者修改在组织里的角色
+	AuditImpersonateStart  = "impersonation.start"
+	AuditImpersonateAction = "impersonation.request" //模拟登录期间的每个请求
+)
+
+// AuditLog 管理操作的审计日志，只追加不修改
+// 每条记录的 Hash 是包含上一条 Hash 的 HMAC，中间任何一条被改动或删除都能校验出来
+// 密钥不在数据库里，只能改数据库的人没法重算整条链；删掉末尾的记录靠库外的检查点发现
+type AuditLog struct {
+	Id         int       `json:"id" gorm:"PRIMARY_KEY"`
+	TenantId   int       `json:"tenant_id" gorm:"index"` //操作发生在哪个组织，查询时按组织隔离
+	ActorId    int       `json:"actor_id" gorm:"index"`
+	ActorName  string    `json:"actor_name"`
+	Action     string    `json:"action" gorm:"type:varchar(64);index"`
+	TargetType string    `json:"target_type" gorm:"type:varchar(64)"`
+	TargetId   int       `json:"target_id" gorm:"index"`
+	Before     string    `json:"before" gorm:"type:text"`
+	After      string    `json:"after" gorm:"type:text"`
+	Diff       string    `json:"diff" gorm:"type:text"`
+	RequestId  string    `json:"request_id" gorm:"type:varchar(64)"`
+	PrevHash   string    `json:"prev_hash" gorm:"type:char(64)"`
+	Hash       string    `json:"hash" gorm:"type:char(64);unique_index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditFilter 审计日志查询条件，零值表示不限制
+type AuditFilter struct {
+	TenantId int
+	ActorId  int
+	TargetId int
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Offset   int
+}
+
+// 审计日志中不能出现的字段，只记录是否修改过
+var auditMasked = map[string]bool{"password": true}
+
+// AuditKey 计算审计日志 Hash 的 HMAC 密钥，main 里从环境变量 AUDIT_HMAC_KEY 读取，不能放在数据库里
+var AuditKey []byte
+
+// 追加要读上一条的hash，同一进程内串行化
+var auditMu sync.Mutex
+
+// computeHash 按固定顺序拼接字段后用 AuditKey 做 HMAC-SHA256
+func (a *AuditLog) computeHash() string {
+	data := fmt.Sprintf("%s\n%d\n%d\n%s\n%s\n%s\n%d\n%s\n%s\n%s\n%s\n%d",
+		a.PrevHash, a.TenantId, a.ActorId, a.ActorName, a.Action, a.TargetType, a.TargetId,
+		a.Before, a.After, a.Diff, a.RequestId, a.CreatedAt.Unix())
+	mac := hmac.New(sha256.New, AuditKey)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditSnapshot 把对象转成map，nil表示对象不存在
+func auditSnapshot(v interface{}) map[string]interface{} {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// auditMask 敏感字段打码后的拷贝
+func auditMask(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	masked := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if auditMasked[k] {
+			v = "***"
+		}
+		masked[k] = v
+	}
+	return masked
+}
+
+// auditDiff 对比前后两个快照，返回 {字段: [旧值, 新值]}，值已打码
+func auditDiff(before, after map[string]interface{}) map[string][2]interface{} {
+	diff := map[string][2]interface{}{}
+	mb, ma := auditMask(before), auditMask(after)
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	for k := range keys {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			diff[k] = [2]interface{}{mb[k], ma[k]}
+		}
+	}
+	return diff
+}
+
+// auditTenant 从快照里取组织，用户是 tenant_id，成员关系是 organization_id
+func auditTenant(snapshots ...map[string]interface{}) int {
+	for _, m := range snapshots {
+		for _, key := range []string{"tenant_id", "organization_id"} {
+			if v, ok := m[key].(float64); ok && v != 0 {
+				return int(v)
+			}
+		}
+	}
+	return 0
+}
+
+// AppendAudit 追加一条审计日志，before/after 为nil表示不存在（新建或删除）
+// 对用户的新建、修改、删除同时写一条 UserEvent
+// 修改用户的操作不要在提交之后再调用，把 entry 传给修改的方法，和修改在同一个事务里写
+func AppendAudit(entry *AuditLog, before, after interface{}) (err error) {
+	defer translate(&err)
+	return auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		return before, after, nil
+	})
+}
+
+// auditedTx 在一个事务里执行 fn，entry 不为nil时把 fn 返回的修改前后的数据写进审计日志
+// 审计日志、用户事件、webhook 投递和 fn 的修改一起提交，任何一个失败都整体回滚
+func auditedTx(entry *AuditLog, fn func(tx *gorm.DB) (before, after interface{}, err error)) error {
+	if entry != nil {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+	}
+	tx := orm.Eloquent.Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	before, after, err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	var event *UserEvent
+	if entry != nil {
+		if event, err = appendAudit(tx, entry, before, after); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	auditCommitted(event)
+	return nil
+}
+
+// appendAudit 在事务 tx 里追加审计日志和对应的用户事件，返回写入的用户事件
+// 调用方要持有 auditMu 直到事务结束，tx 不能带租户过滤
+func appendAudit(tx *gorm.DB, entry *AuditLog, before, after interface{}) (*UserEvent, error) {
+	b, a := auditSnapshot(before), auditSnapshot(after)
+	if b != nil {
+		data, _ := json.Marshal(auditMask(b))
+		entry.Before = string(data)
+	}
+	if a != nil {
+		data, _ := json.Marshal(auditMask(a))
+		entry.After = string(data)
+	}
+	data, _ := json.Marshal(auditDiff(b, a))
+	entry.Diff = string(data)
+	if entry.TenantId == 0 { //没有登录的操作（注册、接受邀请等）按被操作的对象算组织
+		entry.TenantId = auditTenant(a, b)
+	}
+
+	var last AuditLog
+	q := tx
+	if tx.Dialect().GetName() != "sqlite3" { //sqlite 不支持 FOR UPDATE，写事务本来就是串行的
+		q = q.Set("gorm:query_option", "FOR UPDATE")
+	}
+	if err := q.Order("id desc").First(&last).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	entry.PrevHash = last.Hash
+	entry.CreatedAt = time.Now().Truncate(time.Second) //数据库里只存到秒
+	entry.Hash = entry.computeHash()
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, err
+	}
+	event, err := userEventFromAudit(tx, entry.Action, before, after)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	event.CreatedAt = entry.CreatedAt
+	if err := tx.Create(event).Error; err != nil {
+		return nil, err
+	}
+	if err := enqueueWebhookDeliveries(tx, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// selfAudit 通过邮件链接完成的操作，没有登录，操作人就是用户自己
+func selfAudit(entry *AuditLog, user User) {
+	if entry == nil {
+		return
+	}
+	entry.TargetId = user.Id
+	if entry.ActorId == 0 {
+		entry.ActorId, entry.ActorName = user.Id, user.Name
+	}
+}
+
+// auditCommitted 事务提交之后通知 SSE 推送，清理旧的用户事件
+func auditCommitted(event *UserEvent) {
+	if event == nil {
+		return
+	}
+	NotifyUserEvent()
+	if err := pruneUserEvents(event.Id); err != nil { //只是清理，不影响已经提交的修改
+		log.Println("prune user events error", err)
+	}
+}
+
+// QueryAuditLogs 按条件查询审计日志
+func QueryAuditLogs(f AuditFilter) (logs []AuditLog, err error) {
+	defer translate(&err)
+	db := orm.Eloquent.Model(&AuditLog{})
+	if f.TenantId != 0 {
+		db = db.Where("tenant_id = ?", f.TenantId)
+	}
+	if f.ActorId != 0 {
+		db = db.Where("actor_id = ?", f.ActorId)
+	}
+	if f.TargetId != 0 {
+		db = db.Where("target_id = ?", f.TargetId)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if !f.Since.IsZero() {
+		db = db.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		db = db.Where("created_at < ?", f.Until)
+	}
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 50
+	}
+	err = db.Order("id desc").Limit(f.Limit).Offset(f.Offset).Find(&logs).Error
+	return
+}
+
+// VerifyAuditChain 从头校验整条链，返回第一条不一致记录的id，全部正常返回0
+// 还会和最近一次检查点对比，检查点之前的记录被删掉时返回检查点的id
+func VerifyAuditChain() (badId int, checked int, err error) {
+	defer translate(&err)
+	cp, err := LastAuditCheckpoint()
+	if err != nil {
+		return
+	}
+	rows, err := orm.Eloquent.Model(&AuditLog{}).Order("id asc").Rows()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	prev := ""
+	for rows.Next() {
+		var entry AuditLog
+		if err = orm.Eloquent.ScanRows(rows, &entry); err != nil {
+			return
+		}
+		checked++
+		if entry.PrevHash != prev || entry.computeHash() != entry.Hash {
+			badId = entry.Id
+			return
+		}
+		if cp != nil && entry.Id == cp.Id {
+			if entry.Hash != cp.Hash || checked != cp.Count {
+				badId = entry.Id
+				return
+			}
+			cp = nil
+		}
+		prev = entry.Hash
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if cp != nil { //检查点记录的那条已经不在了
+		badId = cp.Id
+	}
+	return
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// 邮箱未验证时的处理策略
+const (
+	EmailPolicyNone       = "none"        // 不限制
+	EmailPolicyBlockLogin = "block_login" // 未验证不能登录
+	EmailPolicyLimited    = "limited"     // 可以登录，但部分接口不能用
+)
+
+// 邮箱验证相关的配置
+var (
+	EmailPolicy       = EmailPolicyNone
+	VerifyTokenTTL    = 24 * time.Hour
+	VerifyResendLimit = 3 // 每个账号每小时最多重发几次
+)
+
+var (
+	ErrVerifyTokenInvalid = errors.New("verification token is invalid or expired")
+	ErrTooManyVerifyMails = errors.New("too many verification mails")
+	ErrNoEmail            = errors.New("user has no email")
+)
+
+// EmailVerification 邮箱验证令牌，和重置密码一样只存sha256
+type EmailVerification struct {
+	Id        int    `gorm:"PRIMARY_KEY"`
+	UserId    int    `gorm:"index;not null"`
+	Email     string `gorm:"type:varchar(191);not null"` //发给哪个邮箱，换了邮箱旧链接就不能用了
+	TokenHash string `gorm:"type:char(64);unique_index;not null"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (EmailVerification) TableName() string {
+	return "email_verifications"
+}
+
+// CreateEmailVerification 给用户当前的邮箱生成一个验证令牌
+func (u *User) CreateEmailVerification() (token string, err error) {
+	defer translate(&err)
+	if u.Email == nil {
+		err = ErrNoEmail
+		return
+	}
+	var count int
+	since := time.Now().Add(-time.Hour)
+	if err = orm.Eloquent.Model(&EmailVerification{}).Where("user_id = ? and created_at > ?", u.Id, since).Count(&count).Error; err != nil {
+		return
+	}
+	if count >= VerifyResendLimit {
+		err = ErrTooManyVerifyMails
+		return
+	}
+	if token, err = NewRandomToken(); err != nil {
+		return
+	}
+	verification := EmailVerification{
+		UserId:    u.Id,
+		Email:     *u.Email,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(VerifyTokenTTL),
+	}
+	err = orm.Eloquent.Create(&verification).Error
+	return
+}
+
+// VerifyEmail 校验令牌并把用户邮箱标记为已验证
+// entry 不为nil时在同一个事务里写审计日志和 user.updated 事件，操作人和目标都是这个用户
+func VerifyEmail(token string, entry *AuditLog) (user User, err error) {
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		var verification EmailVerification
+		if err := tx.Where("token_hash = ? and used_at is null and expires_at > ?", HashToken(token), time.Now()).First(&verification).Error; err != nil {
+			return nil, nil, ErrVerifyTokenInvalid
+		}
+		var before User
+		if err := tx.First(&before, verification.UserId).Error; err != nil {
+			return nil, nil, err
+		}
+		if before.GetEmail() != verification.Email {
+			return nil, nil, ErrVerifyTokenInvalid
+		}
+		now := time.Now()
+		if err := tx.Model(&User{Id: before.Id}).Update("email_verified_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Model(&EmailVerification{}).Where("user_id = ? and used_at is null", before.Id).Update("used_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := tx.First(&user, before.Id).Error; err != nil {
+			return nil, nil, err
+		}
+		selfAudit(entry, user)
+		return before, user, nil
+	})
+	return
+}
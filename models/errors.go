@@ -0,0 +1,10 @@
+package models
+
+import (
+	"github.com/xdtest/project/errs"
+)
+
+// translate 在 defer 中使用，把返回的数据库错误翻译成 errs 里的领域错误
+func translate(err *error) {
+	*err = errs.Translate(*err)
+}
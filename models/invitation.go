@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 注册模式
+const (
+	RegistrationOpen       = "open"        // 任何人可以注册
+	RegistrationInviteOnly = "invite_only" // 只能通过邀请注册
+	RegistrationClosed     = "closed"      // 关闭注册
+	RegistrationApproval   = "approval"    // 可以注册，管理员审核通过后才能登录
+)
+
+// 用户状态
+const (
+	UserActive   = "active"
+	UserPending  = "pending"
+	UserRejected = "rejected"
+)
+
+// 邀请相关的配置
+var (
+	RegistrationMode = RegistrationOpen
+	InvitationTTL    = 7 * 24 * time.Hour
+)
+
+var ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+
+// Invitation 管理员发出的邀请，注册后的角色和所属组织在邀请时就定好
+type Invitation struct {
+	Id         int        `json:"id" gorm:"PRIMARY_KEY"`
+	TenantId   int        `json:"tenant_id" gorm:"index;not null"`
+	Email      string     `json:"email" gorm:"type:varchar(191);not null"`
+	Role       int        `json:"role"`
+	TokenHash  string     `json:"-" gorm:"type:char(64);unique_index;not null"`
+	InvitedBy  int        `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	UserId     int        `json:"user_id"` //接受邀请后创建的用户
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// CreateInvitation 新建邀请，返回明文令牌用于发邮件
+func (inv *Invitation) CreateInvitation() (token string, err error) {
+	defer translate(&err)
+	if token, err = NewRandomToken(); err != nil {
+		return
+	}
+	inv.Email = NormalizeEmail(inv.Email)
+	if !ValidEmail(inv.Email) {
+		return "", ErrInvalidEmail
+	}
+	inv.TokenHash = HashToken(token)
+	inv.ExpiresAt = time.Now().Add(InvitationTTL)
+	err = Tenant(inv.TenantId).Create(inv).Error
+	return
+}
+
+// ListInvitations 租户内的邀请
+func ListInvitations(tenantId int) (list []Invitation, err error) {
+	defer translate(&err)
+	err = Tenant(tenantId).Order("id desc").Find(&list).Error
+	return
+}
+
+// AcceptInvitation 用邀请令牌创建用户，邀请的邮箱直接视为已验证
+// 先在事务里占用邀请再建用户，并发使用同一个邀请时只有一个能成功
+// entry 不为nil时在同一个事务里写审计日志和用户事件，TargetId 自动填新用户的id
+func AcceptInvitation(token string, user *User, entry *AuditLog) (inv Invitation, err error) {
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		if err := tx.Where("token_hash = ? and accepted_at is null and expires_at > ?", HashToken(token), time.Now()).First(&inv).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				err = ErrInvitationInvalid
+			}
+			return nil, nil, err
+		}
+		now := time.Now()
+		result := tx.Model(&Invitation{}).Where("id = ? and accepted_at is null", inv.Id).Update("accepted_at", now)
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		if result.RowsAffected != 1 { //并发时被别人先用掉了
+			return nil, nil, ErrInvitationInvalid
+		}
+		user.TenantId = inv.TenantId
+		user.Role = inv.Role
+		user.Status = UserActive
+		user.SetEmail(inv.Email)
+		user.EmailVerifiedAt = &now
+		if err := user.adduser(tx); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Model(&Invitation{}).Where("id = ?", inv.Id).Update("user_id", user.Id).Error; err != nil {
+			return nil, nil, err
+		}
+		inv.AcceptedAt = &now
+		inv.UserId = user.Id
+		if entry != nil {
+			entry.TargetId = user.Id
+		}
+		return nil, *user, nil
+	})
+	return
+}
+
+// ListPendingUsers 等待审核的注册申请
+func ListPendingUsers(tenantId int) (users []User, err error) {
+	defer translate(&err)
+	err = Tenant(tenantId).Where("status = ?", UserPending).Order("id asc").Find(&users).Error
+	return
+}
+
+// SetUserStatus 审核注册申请，只能处理待审核的用户，返回修改后的用户
+// entry 不为nil时在同一个事务里写审计日志和用户事件
+func SetUserStatus(tenantId, id int, status string, entry *AuditLog) (after User, err error) {
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		var before User
+		if err := tenantDB(tx, tenantId).Where("status = ?", UserPending).First(&before, id).Error; err != nil {
+			return nil, nil, err
+		}
+		result := tenantDB(tx, tenantId).Model(&User{}).Where("id = ? and status = ?", id, UserPending).Update("status", status)
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, nil, gorm.ErrRecordNotFound
+		}
+		if err := tx.First(&after, id).Error; err != nil {
+			return nil, nil, err
+		}
+		return before, after, nil
+	})
+	return
+}
+
+// LoginBlocked 账号状态或邮箱验证不允许登录时返回提示，允许登录返回空字符串
+func (u *User) LoginBlocked() string {
+	switch u.Status {
+	case UserPending:
+		return "注册申请正在等待管理员审核"
+	case UserRejected:
+		return "注册申请未通过"
+	}
+	if EmailPolicy == EmailPolicyBlockLogin && !u.EmailVerified() {
+		return "邮箱未验证，请先完成验证"
+	}
+	return ""
+}
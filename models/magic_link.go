@@ -0,0 +1,160 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	orm "github.com/xdtest/project/database"
+)
+
+// 免密登录链接相关的配置
+var (
+	MagicLinkTTL   = 10 * time.Minute
+	MagicLinkLimit = 5 // 每个账号每小时最多申请几次
+)
+
+var (
+	ErrMagicLinkInvalid = errors.New("magic link is invalid or expired")
+	ErrTooManyMagicLink = errors.New("too many magic link requests")
+)
+
+// MagicLink 免密登录的一次性链接
+// DeviceHash 是申请时浏览器cookie的hash，用来判断点链接的是不是同一个浏览器
+// CodeHash 是申请时显示在那个浏览器上的确认码，在其它设备打开链接时要输入它
+type MagicLink struct {
+	Id         int    `gorm:"PRIMARY_KEY"`
+	UserId     int    `gorm:"index;not null"`
+	TokenHash  string `gorm:"type:char(64);unique_index;not null"`
+	DeviceHash string `gorm:"type:char(64)"`
+	CodeHash   string `gorm:"type:char(64)"`
+	ExpiresAt  time.Time
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+}
+
+func (MagicLink) TableName() string {
+	return "magic_links"
+}
+
+// CreateMagicLink 生成一个免密登录令牌，device是申请方浏览器的标识，code是显示给申请方的确认码
+func (u *User) CreateMagicLink(device, code string) (token string, err error) {
+	defer translate(&err)
+	var count int
+	since := time.Now().Add(-time.Hour)
+	if err = orm.Eloquent.Model(&MagicLink{}).Where("user_id = ? and created_at > ?", u.Id, since).Count(&count).Error; err != nil {
+		return
+	}
+	if count >= MagicLinkLimit {
+		err = ErrTooManyMagicLink
+		return
+	}
+	if token, err = NewRandomToken(); err != nil {
+		return
+	}
+	link := MagicLink{
+		UserId:     u.Id,
+		TokenHash:  HashToken(token),
+		DeviceHash: HashToken(device),
+		CodeHash:   HashToken(code),
+		ExpiresAt:  time.Now().Add(MagicLinkTTL),
+	}
+	err = orm.Eloquent.Create(&link).Error
+	return
+}
+
+// FindMagicLink 查找一个还能用的链接，不消耗
+func FindMagicLink(token string) (link MagicLink, err error) {
+	defer translate(&err)
+	if err = orm.Eloquent.Where("token_hash = ? and used_at is null and expires_at > ?", HashToken(token), time.Now()).First(&link).Error; err != nil {
+		err = ErrMagicLinkInvalid
+	}
+	return
+}
+
+// SameDevice 判断是不是申请链接的那个浏览器
+func (l *MagicLink) SameDevice(device string) bool {
+	return device != "" && l.DeviceHash == HashToken(device)
+}
+
+// NewConfirmCode 生成6位数字的确认码
+func NewConfirmCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// ConsumeMagicLink 消耗链接并返回对应用户，通过邮件登录也说明邮箱是本人的
+// 调用方要先确认是申请链接的浏览器
+// 邮箱因此变成已验证时，entry 不为nil就在同一个事务里写审计日志和 user.updated 事件
+func ConsumeMagicLink(token string, entry *AuditLog) (user User, err error) {
+	return consumeMagicLink(token, nil, entry)
+}
+
+// ConfirmMagicLink 在其它设备打开链接时，用申请时显示的确认码消耗链接
+// 确认码错误时链接同样作废，不能反复猜
+func ConfirmMagicLink(token, code string, entry *AuditLog) (user User, err error) {
+	return consumeMagicLink(token, func(link MagicLink) bool {
+		return link.CodeHash != "" && subtle.ConstantTimeCompare([]byte(link.CodeHash), []byte(HashToken(code))) == 1
+	}, entry)
+}
+
+func consumeMagicLink(token string, check func(link MagicLink) bool, entry *AuditLog) (user User, err error) {
+	defer translate(&err)
+	if entry != nil {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+	}
+	tx := orm.Eloquent.Begin()
+	now := time.Now()
+	result := tx.Model(&MagicLink{}).Where("token_hash = ? and used_at is null and expires_at > ?", HashToken(token), now).Update("used_at", now)
+	if result.Error != nil || result.RowsAffected != 1 {
+		tx.Rollback()
+		err = ErrMagicLinkInvalid
+		return
+	}
+	var link MagicLink
+	if err = tx.Where("token_hash = ?", HashToken(token)).First(&link).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if check != nil && !check(link) {
+		if err = tx.Commit().Error; err == nil { //保留 used_at，链接作废
+			err = ErrMagicLinkInvalid
+		}
+		return
+	}
+	if err = tx.First(&user, link.UserId).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	var event *UserEvent
+	if user.Email != nil && user.EmailVerifiedAt == nil {
+		before := user
+		if err = tx.Model(&User{Id: user.Id}).Update("email_verified_at", now).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+		if err = tx.First(&user, user.Id).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+		if entry != nil {
+			selfAudit(entry, user)
+			if event, err = appendAudit(tx, entry, before, user); err != nil {
+				tx.Rollback()
+				return
+			}
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return
+	}
+	auditCommitted(event)
+	return
+}
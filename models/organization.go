@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// DefaultTenantId 默认组织，多租户之前的老数据都属于它
+const DefaultTenantId = 1
+
+var ErrNotMember = errors.New("user is not a member of the organization")
+
+// ErrNotInvited 用户既不是组织成员，也没有发给他邮箱的有效邀请
+var ErrNotInvited = errors.New("user is not a member and has no pending invitation")
+
+// Organization 组织，也就是租户
+type Organization struct {
+	Id        int       `json:"id" gorm:"PRIMARY_KEY"`
+	Name      string    `json:"name" gorm:"not null"`
+	Slug      string    `json:"slug" gorm:"type:varchar(64);unique_index;not null"` //登录、注册时用来指定租户
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// Membership 用户属于哪些组织，以及在每个组织里的角色
+type Membership struct {
+	Id             int       `json:"id" gorm:"PRIMARY_KEY"`
+	UserId         int       `json:"user_id" gorm:"unique_index:uix_memberships_user_org;not null"`
+	OrganizationId int       `json:"organization_id" gorm:"unique_index:uix_memberships_user_org;not null"`
+	Role           int       `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (Membership) TableName() string {
+	return "memberships"
+}
+
+// EnsureDefaultOrganization 启动时保证默认组织存在
+func EnsureDefaultOrganization() (err error) {
+	defer translate(&err)
+	org := Organization{Id: DefaultTenantId, Name: "Default", Slug: "default"}
+	return orm.Eloquent.Where(Organization{Id: DefaultTenantId}).FirstOrCreate(&org).Error
+}
+
+// CreateOrganization 新建组织
+func (o *Organization) CreateOrganization() (err error) {
+	defer translate(&err)
+	return orm.Eloquent.Create(o).Error
+}
+
+// FindOrganization 按slug查找组织，空slug是默认组织
+func FindOrganization(slug string) (org Organization, err error) {
+	defer translate(&err)
+	if slug == "" {
+		slug = "default"
+	}
+	err = orm.Eloquent.Where("slug = ?", slug).First(&org).Error
+	return
+}
+
+// AddMember 把用户加入组织，已经是成员时更新角色
+func AddMember(orgId, userId, role int) (m Membership, err error) {
+	defer translate(&err)
+	return addMember(orm.Eloquent, orgId, userId, role)
+}
+
+// addMember 同 AddMember，可以在事务里用
+func addMember(db *gorm.DB, orgId, userId, role int) (m Membership, err error) {
+	err = db.Where(Membership{UserId: userId, OrganizationId: orgId}).
+		Assign(map[string]interface{}{"role": role}).FirstOrCreate(&m).Error //用map才能把角色改成0
+	return
+}
+
+// SetMemberRole 管理员修改成员在组织里的角色
+// 不是成员时必须有这个组织发给他邮箱的有效邀请，加入后邀请视为已接受
+// entry 不为nil时在同一个事务里写审计日志和用户事件
+func SetMemberRole(orgId, userId, role int, entry *AuditLog) (m Membership, err error) {
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		var before interface{}
+		var existing Membership
+		err := tx.Where("organization_id = ? and user_id = ?", orgId, userId).First(&existing).Error
+		if gorm.IsRecordNotFoundError(err) {
+			err = claimInvitation(tx, orgId, userId)
+		} else if err == nil {
+			before = existing
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if m, err = addMember(tx, orgId, userId, role); err != nil {
+			return nil, nil, err
+		}
+		return before, m, nil
+	})
+	return
+}
+
+// claimInvitation 把组织发给用户邮箱的一个有效邀请标记为已接受，没有时返回 ErrNotInvited
+func claimInvitation(tx *gorm.DB, orgId, userId int) error {
+	var user User
+	if err := tx.First(&user, userId).Error; err != nil {
+		return err
+	}
+	if user.Email == nil {
+		return ErrNotInvited
+	}
+	now := time.Now()
+	var inv Invitation
+	err := tx.Where("tenant_id = ? and email = ? and accepted_at is null and expires_at > ?", orgId, *user.Email, now).First(&inv).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrNotInvited
+	}
+	if err != nil {
+		return err
+	}
+	result := tx.Model(&Invitation{}).Where("id = ? and accepted_at is null", inv.Id).
+		Updates(map[string]interface{}{"accepted_at": now, "user_id": userId})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotInvited
+	}
+	return nil
+}
+
+// ListMemberships 用户加入的所有组织
+func ListMemberships(userId int) (list []Membership, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.Where("user_id = ?", userId).Find(&list).Error
+	return
+}
+
+// MemberRole 用户在某个组织里的角色，不是成员时返回 ErrNotMember，数据库出错时返回翻译后的错误
+func MemberRole(orgId, userId int) (role int, err error) {
+	defer translate(&err)
+	var m Membership
+	err = orm.Eloquent.Where("organization_id = ? and user_id = ?", orgId, userId).First(&m).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, ErrNotMember
+	}
+	return m.Role, err
+}
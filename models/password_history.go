@@ -0,0 +1,85 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/password"
+)
+
+var ErrPasswordReused = errors.New("password was used recently")
+
+// PasswordHistory 用户用过的密码，只存加盐的hash，用来防止重复使用
+type PasswordHistory struct {
+	Id        int    `gorm:"PRIMARY_KEY"`
+	UserId    int    `gorm:"index;not null"`
+	Salt      string `gorm:"type:char(64);not null"`
+	Hash      string `gorm:"type:char(64);not null"`
+	CreatedAt time.Time
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+func historyHash(salt, pw string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(pw))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckPasswordHistory 新密码不能和最近 HistorySize 次用过的相同
+func CheckPasswordHistory(userId int, pw string) (err error) {
+	defer translate(&err)
+	return checkPasswordHistory(orm.Eloquent, userId, pw)
+}
+
+// checkPasswordHistory 同 CheckPasswordHistory，可以在事务里用
+func checkPasswordHistory(db *gorm.DB, userId int, pw string) error {
+	size := password.Default.HistorySize
+	if size <= 0 {
+		return nil
+	}
+	var histories []PasswordHistory
+	if err := db.Where("user_id = ?", userId).Order("id desc").Limit(size).Find(&histories).Error; err != nil {
+		return err
+	}
+	for _, h := range histories {
+		if hmac.Equal([]byte(h.Hash), []byte(historyHash(h.Salt, pw))) {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// RecordPassword 记录一次密码，只保留最近 HistorySize 条
+func RecordPassword(userId int, pw string) (err error) {
+	defer translate(&err)
+	return recordPassword(orm.Eloquent, userId, pw)
+}
+
+// recordPassword 同 RecordPassword，可以在事务里用
+func recordPassword(db *gorm.DB, userId int, pw string) error {
+	size := password.Default.HistorySize
+	if size <= 0 {
+		return nil
+	}
+	salt, err := NewRandomToken()
+	if err != nil {
+		return err
+	}
+	history := PasswordHistory{UserId: userId, Salt: salt, Hash: historyHash(salt, pw)}
+	if err = db.Create(&history).Error; err != nil {
+		return err
+	}
+	var keep []int
+	if err = db.Model(&PasswordHistory{}).Where("user_id = ?", userId).Order("id desc").Limit(size).Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ? and id not in (?)", userId, keep).Delete(&PasswordHistory{}).Error
+}
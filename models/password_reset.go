@@ -0,0 +1,154 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/password"
+)
+
+// 重置密码相关的配置
+var (
+	ResetTokenTTL      = 30 * time.Minute // 链接有效期
+	ResetRequestLimit  = 3                // 每个账号在ResetRequestWindow内最多申请几次
+	ResetRequestWindow = time.Hour
+)
+
+var (
+	ErrResetTokenInvalid = errors.New("reset token is invalid or expired")
+	ErrTooManyResets     = errors.New("too many password reset requests")
+)
+
+// PasswordReset 重置密码的一次性令牌，库里只存sha256
+type PasswordReset struct {
+	Id        int    `gorm:"PRIMARY_KEY"`
+	UserId    int    `gorm:"index;not null"`
+	TokenHash string `gorm:"type:char(64);unique_index;not null"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (PasswordReset) TableName() string {
+	return "password_resets"
+}
+
+// HashToken 对令牌做sha256，数据库里不保存明文
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRandomToken 生成一个随机令牌
+func NewRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// CreatePasswordReset 给用户生成一个重置令牌，返回明文令牌用于发邮件
+func (u *User) CreatePasswordReset() (token string, err error) {
+	defer translate(&err)
+	var count int
+	since := time.Now().Add(-ResetRequestWindow)
+	if err = orm.Eloquent.Model(&PasswordReset{}).Where("user_id = ? and created_at > ?", u.Id, since).Count(&count).Error; err != nil {
+		return
+	}
+	if count >= ResetRequestLimit {
+		err = ErrTooManyResets
+		return
+	}
+	if token, err = NewRandomToken(); err != nil {
+		return
+	}
+	reset := PasswordReset{
+		UserId:    u.Id,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(ResetTokenTTL),
+	}
+	err = orm.Eloquent.Create(&reset).Error
+	return
+}
+
+// ResetPassword 用令牌重置密码，令牌只能用一次，用完后该用户其它未用的令牌一起作废
+// 重置后该用户所有的会话都被吊销，需要用新密码重新登录；返回的是修改前的用户
+// entry 不为nil时在同一个事务里写审计日志和用户事件，操作人没填时记为该用户自己
+func ResetPassword(token, pw string, entry *AuditLog) (user User, err error) {
+	defer translate(&err)
+	if entry != nil {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+	}
+	var reset PasswordReset
+	tx := orm.Eloquent.Begin()
+	if err = tx.Where("token_hash = ? and used_at is null and expires_at > ?", HashToken(token), time.Now()).First(&reset).Error; err != nil {
+		tx.Rollback()
+		err = ErrResetTokenInvalid
+		return
+	}
+	// 先占用令牌，同一个令牌的并发请求只有一个能成功
+	now := time.Now()
+	result := tx.Model(&PasswordReset{}).Where("id = ? and used_at is null", reset.Id).Update("used_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		err = result.Error
+		return
+	}
+	if result.RowsAffected != 1 {
+		tx.Rollback()
+		err = ErrResetTokenInvalid
+		return
+	}
+	if err = tx.First(&user, reset.UserId).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = password.Default.Validate(pw, user.Name); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = checkPasswordHistory(tx, user.Id, pw); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Model(&User{}).Where("id = ?", user.Id).Update("password", pw).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Model(&PasswordReset{}).Where("user_id = ? and used_at is null", user.Id).Update("used_at", now).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = revokeSessions(tx, user.Id); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = recordPassword(tx, user.Id, pw); err != nil {
+		tx.Rollback()
+		return
+	}
+	var event *UserEvent
+	if entry != nil {
+		var after User
+		if err = tx.First(&after, user.Id).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+		selfAudit(entry, user)
+		if event, err = appendAudit(tx, entry, user, after); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return
+	}
+	auditCommitted(event)
+	return
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	orm "github.com/xdtest/project/database"
+)
+
+func TestResetPassword(t *testing.T) {
+	defer setupDB(t)()
+	alice := createUser(t, "alice")
+	session, err := alice.CreateSession("test", "go-test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	reset := func(pw string) error {
+		token, err := alice.CreatePasswordReset()
+		if err != nil {
+			t.Fatalf("create password reset: %v", err)
+		}
+		_, err = ResetPassword(token, pw, &AuditLog{Action: AuditUserPasswordReset, TargetType: "user"})
+		return err
+	}
+
+	// 密码历史在同一个事务里查，不会去等事务占着的连接
+	if err := reset(testPassword); !errors.Is(err, ErrPasswordReused) {
+		t.Fatalf("reset to the current password = %v, want %v", err, ErrPasswordReused)
+	}
+	if _, err := FindActiveSession(session.TokenId); err != nil {
+		t.Errorf("session after a failed reset: %v", err)
+	}
+
+	if err := reset("Battery-Staple-77"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if u, _ := GetUser(alice.Id); u.Password != "Battery-Staple-77" {
+		t.Errorf("password not changed")
+	}
+	if _, err := FindActiveSession(session.TokenId); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("session after reset = %v, want %v", err, ErrSessionRevoked)
+	}
+	// 审计日志和 user.updated 事件和新密码一起提交
+	var logs []AuditLog
+	if err := orm.Eloquent.Where("action = ? and target_id = ?", AuditUserPasswordReset, alice.Id).Find(&logs).Error; err != nil || len(logs) != 1 {
+		t.Errorf("got %d password reset audit logs (%v), want 1", len(logs), err)
+	} else if logs[0].ActorId != alice.Id || strings.Contains(logs[0].Before+logs[0].After+logs[0].Diff, "Battery-Staple-77") {
+		t.Errorf("audit log = %+v, want alice as actor and no password", logs[0])
+	}
+	events, err := ListUserEvents(UserEventFilter{UserId: alice.Id, Types: []string{UserUpdated}})
+	if err != nil || len(events) != 1 {
+		t.Errorf("got %d user.updated events (%v), want 1", len(events), err)
+	}
+	// 新密码也记进了历史
+	if err := reset("Battery-Staple-77"); !errors.Is(err, ErrPasswordReused) {
+		t.Errorf("reset to the new password again = %v, want %v", err, ErrPasswordReused)
+	}
+}
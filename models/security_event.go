@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	orm "github.com/xdtest/project/database"
+)
+
+// 安全事件类型
+const (
+	EventLoginSuccess  = "login_success"
+	EventWrongPassword = "wrong_password"
+	EventUnknownUser   = "unknown_user"
+	EventTokenExpired  = "token_expired"
+	EventAccountLocked = "account_locked"
+	EventNewDevice     = "new_device"
+)
+
+// 安全事件相关的配置
+var (
+	SecurityEventRetention = 90 * 24 * time.Hour // 事件保留多久
+	LockoutThreshold       = 5                   // LockoutWindow 内密码错误几次后锁定
+	LockoutWindow          = 15 * time.Minute
+)
+
+// SecurityEvent 一次认证相关的事件，未知用户的事件 UserId 为0，只记录尝试的账号
+type SecurityEvent struct {
+	Id         int       `json:"id" gorm:"PRIMARY_KEY"`
+	UserId     int       `json:"user_id" gorm:"index"`
+	Account    string    `json:"account" gorm:"type:varchar(191);index"`
+	Type       string    `json:"type" gorm:"type:varchar(32);not null"`
+	IP         string    `json:"ip" gorm:"column:ip;type:varchar(64)"`
+	UserAgent  string    `json:"user_agent" gorm:"type:varchar(512)"`
+	DeviceHash string    `json:"-" gorm:"type:char(64);index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+func (SecurityEvent) TableName() string {
+	return "security_events"
+}
+
+// RecordSecurityEvent 记录一条安全事件
+func RecordSecurityEvent(event *SecurityEvent) (err error) {
+	defer translate(&err)
+	return orm.Eloquent.Create(event).Error
+}
+
+// ListSecurityEvents 按时间倒序分页查询用户的安全事件
+func ListSecurityEvents(userId, limit, offset int) (events []SecurityEvent, err error) {
+	defer translate(&err)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	err = orm.Eloquent.Where("user_id = ?", userId).Order("id desc").Limit(limit).Offset(offset).Find(&events).Error
+	return
+}
+
+// RecentSecurityEvents 一次查出多个用户各自最近的 limit 条事件，按用户id分组，给 GraphQL 批量加载用
+// 每个用户单独 limit 后 union 起来，不依赖窗口函数
+func RecentSecurityEvents(userIds []int, limit int) (events map[int][]SecurityEvent, err error) {
+	defer translate(&err)
+	events = make(map[int][]SecurityEvent, len(userIds))
+	if len(userIds) == 0 {
+		return
+	}
+	parts := make([]string, 0, len(userIds))
+	args := make([]interface{}, 0, 2*len(userIds))
+	for i, id := range userIds {
+		parts = append(parts, fmt.Sprintf("select * from (select * from security_events where user_id = ? order by id desc limit ?) t%d", i))
+		args = append(args, id, limit)
+	}
+	var list []SecurityEvent
+	if err = orm.Eloquent.Raw(strings.Join(parts, " union all "), args...).Scan(&list).Error; err != nil {
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id > list[j].Id })
+	for _, e := range list {
+		events[e.UserId] = append(events[e.UserId], e)
+	}
+	return
+}
+
+// IsKnownDevice 这个设备以前有没有成功登录过
+// 第一次登录时的新设备事件记的是下发cookie之前的标识，也算见过
+func IsKnownDevice(userId int, deviceHash string) (known bool, err error) {
+	defer translate(&err)
+	var count int
+	err = orm.Eloquent.Model(&SecurityEvent{}).
+		Where("user_id = ? and type in (?) and device_hash = ?", userId, []string{EventLoginSuccess, EventNewDevice}, deviceHash).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// IsLocked 最近 LockoutWindow 内密码错误次数达到阈值就锁定账号，成功登录后重新计数
+func IsLocked(userId int) (locked bool, err error) {
+	defer translate(&err)
+	if LockoutThreshold <= 0 {
+		return false, nil
+	}
+	var last SecurityEvent
+	since := time.Now().Add(-LockoutWindow)
+	err = orm.Eloquent.Where("user_id = ? and type = ?", userId, EventLoginSuccess).Order("id desc").First(&last).Error
+	if err == nil && last.CreatedAt.After(since) {
+		since = last.CreatedAt
+	}
+	var count int
+	err = orm.Eloquent.Model(&SecurityEvent{}).
+		Where("user_id = ? and type = ? and created_at > ?", userId, EventWrongPassword, since).
+		Count(&count).Error
+	return count >= LockoutThreshold, err
+}
+
+// PurgeSecurityEvents 删除超过保留期的事件
+func PurgeSecurityEvents() (n int64, err error) {
+	defer translate(&err)
+	result := orm.Eloquent.Where("created_at < ?", time.Now().Add(-SecurityEventRetention)).Delete(&SecurityEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// 会话相关的配置
+var (
+	MaxSessions          = 5           // 每个用户最多同时几个会话，超过时踢掉最早的，0表示不限制
+	SessionTouchInterval = time.Minute // last_seen_at 的更新间隔，避免每个请求都写库
+)
+
+var ErrSessionRevoked = errors.New("session is revoked")
+
+// Session 一次登录，对应token里的jti
+type Session struct {
+	Id         int        `json:"id" gorm:"PRIMARY_KEY"`
+	UserId     int        `json:"-" gorm:"index;not null"`
+	TokenId    string     `json:"-" gorm:"type:char(64);unique_index;not null"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent" gorm:"type:varchar(512)"`
+	IP         string     `json:"ip" gorm:"column:ip;type:varchar(64)"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// CreateSession 登录时记录一个会话，超过上限时吊销最早的会话
+func (u *User) CreateSession(deviceName, userAgent, ip string) (session Session, err error) {
+	defer translate(&err)
+	tokenId, err := NewRandomToken()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	session = Session{
+		UserId:     u.Id,
+		TokenId:    tokenId,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+	}
+	if err = orm.Eloquent.Create(&session).Error; err != nil {
+		return
+	}
+	if MaxSessions <= 0 {
+		return
+	}
+	var keep []int
+	if err = orm.Eloquent.Model(&Session{}).Where("user_id = ? and revoked_at is null", u.Id).
+		Order("id desc").Limit(MaxSessions).Pluck("id", &keep).Error; err != nil {
+		return
+	}
+	err = orm.Eloquent.Model(&Session{}).Where("user_id = ? and revoked_at is null and id not in (?)", u.Id, keep).
+		Update("revoked_at", now).Error
+	return
+}
+
+// FindActiveSession 根据token里的jti找到还有效的会话，顺便更新最后活跃时间
+func FindActiveSession(tokenId string) (session Session, err error) {
+	defer translate(&err)
+	if tokenId == "" {
+		err = ErrSessionRevoked
+		return
+	}
+	if err = orm.Eloquent.Where("token_id = ?", tokenId).First(&session).Error; err != nil {
+		err = ErrSessionRevoked
+		return
+	}
+	if session.RevokedAt != nil {
+		err = ErrSessionRevoked
+		return
+	}
+	if time.Since(session.LastSeenAt) > SessionTouchInterval {
+		session.LastSeenAt = time.Now()
+		err = orm.Eloquent.Model(&session).UpdateColumn("last_seen_at", session.LastSeenAt).Error
+	}
+	return
+}
+
+// ListSessions 用户当前有效的会话
+func ListSessions(userId int) (sessions []Session, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.Where("user_id = ? and revoked_at is null", userId).Order("last_seen_at desc").Find(&sessions).Error
+	return
+}
+
+// ListSessionsByUsers 一次查出多个用户当前有效的会话，按用户id分组，给 GraphQL 批量加载用
+func ListSessionsByUsers(userIds []int) (sessions map[int][]Session, err error) {
+	defer translate(&err)
+	var list []Session
+	if err = orm.Eloquent.Where("user_id in (?) and revoked_at is null", userIds).Order("last_seen_at desc").Find(&list).Error; err != nil {
+		return
+	}
+	sessions = make(map[int][]Session, len(userIds))
+	for _, s := range list {
+		sessions[s.UserId] = append(sessions[s.UserId], s)
+	}
+	return
+}
+
+// RevokeSession 吊销用户自己的某个会话
+func RevokeSession(userId, id int) (err error) {
+	defer translate(&err)
+	result := orm.Eloquent.Model(&Session{}).Where("id = ? and user_id = ? and revoked_at is null", id, userId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// revokeSessions 吊销用户所有的会话，删除用户、修改密码时和修改在同一个事务里调用
+func revokeSessions(db *gorm.DB, userId int) error {
+	return db.Model(&Session{}).Where("user_id = ? and revoked_at is null", userId).Update("revoked_at", time.Now()).Error
+}
+
+// RevokeOtherSessions 退出除当前会话以外的所有会话
+func RevokeOtherSessions(userId int, currentTokenId string) (n int64, err error) {
+	defer translate(&err)
+	result := orm.Eloquent.Model(&Session{}).Where("user_id = ? and token_id <> ? and revoked_at is null", userId, currentTokenId).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
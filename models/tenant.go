@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// 有 TenantId 字段的表自动按租户过滤
+// 用 Tenant(id) 拿到的连接做查询、更新、删除时会自动加上 tenant_id = ?，新建时自动填上 TenantId
+const tenantKey = "tenant:id"
+
+func init() {
+	registerTenantCallbacks(orm.Eloquent)
+}
+
+func registerTenantCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	cb.Query().Before("gorm:query").Register("tenant:scope", tenantScope)
+	cb.RowQuery().Before("gorm:row_query").Register("tenant:scope", tenantScope)
+	cb.Update().Before("gorm:update").Register("tenant:scope", tenantScope)
+	cb.Delete().Before("gorm:delete").Register("tenant:scope", tenantScope)
+	cb.Create().Before("gorm:create").Register("tenant:assign", tenantAssign)
+}
+
+// UseDB 换用另一个数据库连接，并注册按租户过滤、版本号加一的回调，测试里用来换成 sqlite
+func UseDB(db *gorm.DB) {
+	registerTenantCallbacks(db)
+	registerVersionCallbacks(db)
+	orm.Eloquent = db
+}
+
+// Tenant 返回按租户隔离的连接，0 表示默认组织
+func Tenant(tenantId int) *gorm.DB {
+	return tenantDB(orm.Eloquent, tenantId)
+}
+
+// tenantDB 同 Tenant，可以在事务里用
+func tenantDB(db *gorm.DB, tenantId int) *gorm.DB {
+	if tenantId == 0 {
+		tenantId = DefaultTenantId
+	}
+	return db.Set(tenantKey, tenantId)
+}
+
+func tenantScope(scope *gorm.Scope) {
+	id, ok := scope.Get(tenantKey)
+	if !ok {
+		return
+	}
+	if _, ok := scope.FieldByName("TenantId"); ok {
+		scope.Search.Where(scope.QuotedTableName()+".tenant_id = ?", id)
+	}
+}
+
+func tenantAssign(scope *gorm.Scope) {
+	id, ok := scope.Get(tenantKey)
+	if !ok {
+		return
+	}
+	if field, ok := scope.FieldByName("TenantId"); ok {
+		field.Set(id)
+	}
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// 用户事件类型
+const (
+	UserCreated     = "user.created"
+	UserUpdated     = "user.updated"
+	UserDeleted     = "user.deleted"
+	UserRoleChanged = "user.role_changed" //角色变了只记这一种，不再另记 user.updated
+)
+
+// UserEventTypes 所有用户事件类型
+var UserEventTypes = []string{UserCreated, UserUpdated, UserDeleted, UserRoleChanged}
+
+// 用户事件相关的配置
+var (
+	UserEventLimit  = 10000     // user_events 表最多保留的条数，更早的删除，断线太久的客户端无法从断点继续
+	NotifyUserEvent = func() {} // 写入新事件后调用，SSE 推送用来立即唤醒轮询
+)
+
+// UserEvent 用户的新建、修改、删除和角色变化，给 SSE 推送和断线续传用
+// 和审计日志在同一个事务里写入，审计日志里有的用户变化这里都有
+type UserEvent struct {
+	Id        int       `json:"id" gorm:"PRIMARY_KEY"`
+	Type      string    `json:"type" gorm:"type:varchar(32);not null"`
+	TenantId  int       `json:"tenant_id" gorm:"index;not null"`
+	UserId    int       `json:"user_id" gorm:"not null"`
+	Data      string    `json:"-" gorm:"type:text"` //UserEventData 的 JSON
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (UserEvent) TableName() string {
+	return "user_events"
+}
+
+// UserEventData 事件发生后的用户资料，删除时是删除前的，不含密码
+type UserEventData struct {
+	Id              int        `json:"id"`
+	Name            string     `json:"name"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            int        `json:"role"`
+	OldRole         *int       `json:"old_role,omitempty"` //user.role_changed 时原来的角色，刚加入组织时没有
+	TenantId        int        `json:"tenant_id"`
+	Status          string     `json:"status"`
+	Version         int        `json:"version"`
+}
+
+// UserEventMessage 推送给 SSE 客户端和 webhook 的事件内容
+type UserEventMessage struct {
+	Id        int             `json:"id"`
+	Type      string          `json:"type"`
+	UserId    int             `json:"user_id"`
+	TenantId  int             `json:"tenant_id"`
+	User      json.RawMessage `json:"user"` //UserEventData
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Message 对外发送的事件内容
+func (e UserEvent) Message() UserEventMessage {
+	return UserEventMessage{
+		Id:        e.Id,
+		Type:      e.Type,
+		UserId:    e.UserId,
+		TenantId:  e.TenantId,
+		User:      json.RawMessage(e.Data),
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// UserEventFilter 查询条件，零值表示不限制
+type UserEventFilter struct {
+	TenantId int
+	AfterId  int
+	UserId   int
+	Types    []string
+	Limit    int
+}
+
+// userEventFromAudit 审计日志对应的用户事件，不是用户的新建、修改、删除时返回 nil
+// 加入组织、组织里的角色变化审计时传的是 Membership
+func userEventFromAudit(db *gorm.DB, action string, before, after interface{}) (*UserEvent, error) {
+	var (
+		user    User
+		typ     string
+		oldRole *int
+	)
+	switch action {
+	case AuditUserCreate:
+		u, ok := asUser(after)
+		if !ok {
+			return nil, nil
+		}
+		user, typ = u, UserCreated
+	case AuditUserUpdate, AuditUserPasswordReset: //密码不在事件里，重置密码和改资料一样是 user.updated
+		u, ok := asUser(after)
+		if !ok {
+			return nil, nil
+		}
+		user, typ = u, UserUpdated
+		if b, ok := asUser(before); ok && b.Role != u.Role {
+			typ, oldRole = UserRoleChanged, &b.Role
+		}
+	case AuditUserDelete:
+		u, ok := asUser(before)
+		if !ok {
+			return nil, nil
+		}
+		user, typ = u, UserDeleted
+	case AuditMemberRole:
+		m, ok := after.(Membership)
+		if !ok {
+			return nil, nil
+		}
+		if b, ok := before.(Membership); ok {
+			if b.Role == m.Role {
+				return nil, nil
+			}
+			oldRole = &b.Role
+		}
+		if err := db.First(&user, m.UserId).Error; err != nil {
+			return nil, err
+		}
+		user.TenantId, user.Role, typ = m.OrganizationId, m.Role, UserRoleChanged
+	default:
+		return nil, nil
+	}
+	data, _ := json.Marshal(UserEventData{
+		Id:              user.Id,
+		Name:            user.Name,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Role:            user.Role,
+		OldRole:         oldRole,
+		TenantId:        user.TenantId,
+		Status:          user.Status,
+		Version:         user.Version,
+	})
+	return &UserEvent{Type: typ, TenantId: user.TenantId, UserId: user.Id, Data: string(data)}, nil
+}
+
+func asUser(v interface{}) (User, bool) {
+	switch u := v.(type) {
+	case User:
+		return u, true
+	case *User:
+		if u != nil {
+			return *u, true
+		}
+	}
+	return User{}, false
+}
+
+// pruneUserEvents 只保留最近的 UserEventLimit 条
+func pruneUserEvents(lastId int) error {
+	if UserEventLimit <= 0 || lastId <= UserEventLimit {
+		return nil
+	}
+	return orm.Eloquent.Where("id <= ?", lastId-UserEventLimit).Delete(&UserEvent{}).Error
+}
+
+// ListUserEvents 按id顺序查询 AfterId 之后的事件
+func ListUserEvents(f UserEventFilter) (events []UserEvent, err error) {
+	defer translate(&err)
+	db := orm.Eloquent.Where("id > ?", f.AfterId)
+	if f.TenantId != 0 {
+		db = db.Where("tenant_id = ?", f.TenantId)
+	}
+	if f.UserId != 0 {
+		db = db.Where("user_id = ?", f.UserId)
+	}
+	if len(f.Types) > 0 {
+		db = db.Where("type in (?)", f.Types)
+	}
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 100
+	}
+	err = db.Order("id asc").Limit(f.Limit).Find(&events).Error
+	return
+}
+
+// UserEventRange 还保留着的最早和最新一条事件的id，没有事件时都是0
+func UserEventRange() (oldest, latest int, err error) {
+	defer translate(&err)
+	row := orm.Eloquent.Model(&UserEvent{}).Select("coalesce(min(id), 0), coalesce(max(id), 0)").Row()
+	err = row.Scan(&oldest, &latest)
+	return
+}
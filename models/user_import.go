@@ -0,0 +1,187 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/errs"
+)
+
+// 导入时用户名已存在的处理方式
+const (
+	ImportSkip   = "skip"   // 跳过
+	ImportUpsert = "upsert" // 用文件里的邮箱、密码、角色、状态更新
+)
+
+// 导入每一行的结果
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+)
+
+// ImportKeepRole 文件里没写角色，新建时是普通用户，更新时不改角色
+const ImportKeepRole = -1
+
+var ErrImportNoPassword = errors.New("password is required for new users")
+
+// ImportResult 导入一行的结果，Err 是这一行自己的问题，不影响同一批的其它行
+type ImportResult struct {
+	Action string
+	User   User
+	Before User // 更新前的用户，只有 ImportUpdated 时有值
+	Err    error
+}
+
+// ImportUsers 在一个事务里导入一批用户，用户名已存在时按 mode 跳过或更新
+// 数据库出错时整批回滚，返回出错的行下标；dryRun 时执行完也回滚，用来发现唯一约束这类只有数据库能检查的问题
+// 密码策略等校验由调用方先做
+// audit 不为nil时按它的操作人给新建、更新的每一行写审计日志和用户事件，和这一批在同一个事务里提交
+func ImportUsers(tenantId int, users []User, mode string, dryRun bool, audit *AuditLog) (results []ImportResult, failed int, err error) {
+	defer translate(&err)
+	failed = -1
+	if dryRun {
+		audit = nil
+	}
+	if audit != nil {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+	}
+	tx := orm.Eloquent.Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+	var event *UserEvent
+	defer func() {
+		if err != nil || dryRun {
+			tx.Rollback()
+			return
+		}
+		if err = tx.Commit().Error; err == nil {
+			auditCommitted(event)
+		}
+	}()
+	for i, u := range users {
+		var r ImportResult
+		if r, err = importUser(tenantDB(tx, tenantId), tenantId, u, mode); err != nil {
+			failed = i
+			return
+		}
+		if audit != nil && r.Err == nil && r.Action != ImportSkipped {
+			entry := *audit
+			entry.Action, entry.TargetId = AuditUserUpdate, r.User.Id
+			var before interface{} = r.Before
+			if r.Action == ImportCreated {
+				entry.Action, before = AuditUserCreate, nil
+			}
+			var e *UserEvent
+			if e, err = appendAudit(tx, &entry, before, r.User); err != nil {
+				failed = i
+				return
+			}
+			if e != nil {
+				event = e
+			}
+		}
+		results = append(results, r)
+	}
+	return
+}
+
+func importUser(tx *gorm.DB, tenantId int, u User, mode string) (r ImportResult, err error) {
+	var existing User
+	err = tx.Where("name = ?", u.Name).First(&existing).Error
+	if gorm.IsRecordNotFoundError(err) {
+		if u.Password == "" {
+			r.Err = ErrImportNoPassword
+			return r, nil
+		}
+		u.Id = 0
+		u.TenantId = tenantId
+		if u.Role == ImportKeepRole {
+			u.Role = RoleUser
+		}
+		if err = tx.Create(&u).Error; err != nil {
+			return
+		}
+		if _, err = addMember(tx, tenantId, u.Id, u.Role); err != nil {
+			return
+		}
+		if err = recordPassword(tx, u.Id, u.Password); err != nil {
+			return
+		}
+		return ImportResult{Action: ImportCreated, User: u}, nil
+	}
+	if err != nil {
+		return
+	}
+	if mode != ImportUpsert {
+		return ImportResult{Action: ImportSkipped, User: existing}, nil
+	}
+	if u.Password != "" && u.Password != existing.Password { //最近用过的密码不能再用，只算这一行失败
+		if err = checkPasswordHistory(tx, existing.Id, u.Password); err == ErrPasswordReused {
+			r.Err = err
+			return r, nil
+		} else if err != nil {
+			return
+		}
+	}
+	before := existing
+	values := map[string]interface{}{}
+	role := existing.Role
+	if u.Role != ImportKeepRole {
+		role = u.Role
+		values["role_id"] = role
+	}
+	if u.Status != "" {
+		values["status"] = u.Status
+	}
+	if u.Email != nil && u.GetEmail() != existing.GetEmail() {
+		values["email"] = u.Email
+		values["email_verified_at"] = nil
+	}
+	if u.Password != "" && u.Password != existing.Password {
+		values["password"] = u.Password
+	}
+	if len(values) > 0 {
+		if err = tx.Model(&existing).Updates(values).Error; err != nil {
+			return
+		}
+		if err = tx.First(&existing, existing.Id).Error; err != nil { //重新读出版本号等数据库里改的字段
+			return
+		}
+	}
+	if _, err = addMember(tx, tenantId, existing.Id, role); err != nil {
+		return
+	}
+	if _, ok := values["password"]; ok {
+		if err = recordPassword(tx, existing.Id, u.Password); err != nil {
+			return
+		}
+		if err = revokeSessions(tx, existing.Id); err != nil { //和修改密码一样，之前签发的token不能再用
+			return
+		}
+	}
+	return ImportResult{Action: ImportUpdated, User: existing, Before: before}, nil
+}
+
+// EachUser 按id顺序逐行读取租户内的用户交给 fn，不会把所有用户读进内存
+// fn 返回错误时停止，原样返回该错误
+func EachUser(tenantId int, fn func(User) error) error {
+	rows, err := Tenant(tenantId).Model(&User{}).Order("id asc").Rows()
+	if err != nil {
+		return errs.Translate(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u User
+		if err := Tenant(tenantId).ScanRows(rows, &u); err != nil {
+			return errs.Translate(err)
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return errs.Translate(rows.Err())
+}
@@ -1,75 +1,332 @@
 package models
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
 	// "log"
 
+	"github.com/jinzhu/gorm"
 	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/password"
 )
 
 type User struct {
-	Name     string `form:"name",json:"name",bingding:"required",gorm:"unique;not null"`
-	Password string `form:"password",json:"password",bingding:"required"，gorm:"NOT NULL"`
-	Id       int    `form:"id"，gorm:"PRIMARY_KEY"`
-	Role     int    `gorm:"column:role_id"`
+	Name     string  `form:"name" json:"name" binding:"required" gorm:"not null;unique_index:uix_users_tenant_name"` //同一个租户内唯一
+	Password string  `form:"password" json:"password" binding:"required" gorm:"NOT NULL"`
+	Id       int     `form:"id" gorm:"PRIMARY_KEY"`
+	Role     int     `gorm:"column:role_id"`
+	Email    *string `form:"email" json:"email" gorm:"type:varchar(191);unique_index:uix_users_tenant_email"` //统一存小写，没有邮箱时为NULL
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TenantId        int        `json:"tenant_id" gorm:"not null;default:1;unique_index:uix_users_tenant_name,uix_users_tenant_email"` //所属组织
+	Status          string     `json:"status" gorm:"type:varchar(16);not null;default:'active'"`                                      //active, pending, rejected
+	Version         int        `json:"version" gorm:"not null;default:1"`                                                             //每次修改加一，用于乐观锁
+}
+
+// 角色，对应 role_id
+const (
+	RoleUser    = 0
+	RoleAdmin   = 1
+	RoleSupport = 2
+)
+
+// RoleNames 角色名，策略文件里用名字写规则
+var RoleNames = map[int]string{
+	RoleUser:    "user",
+	RoleAdmin:   "admin",
+	RoleSupport: "support",
 }
 
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrInvalidEmail 邮箱格式不正确
+var ErrInvalidEmail = errors.New("invalid email")
+
+// 用户不存在时用来做比较的假密码
+var dummyPassword = strings.Repeat("x", 32)
+
 func (User) TableName() string {
 	return "users"
 }
 
-func (u *User) Adduser() (id int, err error) { //user对象的方法 可以直接user.Adduser方法来完成添加记录
-	result := orm.Eloquent.Create(&u)
-	id = u.Id
-	if result.Error != nil {
-		err = result.Error
-		fmt.Println("这是错误", err, "这是错误")
-		fmt.Printf("sdf%d", id)
+// NormalizeEmail 邮箱去空格转小写，保证大小写不敏感的唯一
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// GetEmail 返回邮箱，没有时返回空字符串
+func (u *User) GetEmail() string {
+	if u.Email == nil {
+		return ""
+	}
+	return *u.Email
+}
+
+// SetEmail 设置邮箱，空字符串存成NULL
+func (u *User) SetEmail(email string) {
+	email = NormalizeEmail(email)
+	if email == "" {
+		u.Email = nil
 		return
 	}
-	return
+	u.Email = &email
+}
 
+// ValidEmail 是否是单独的一个邮箱地址，不能带显示名、尖括号，也不能是多个地址
+func ValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Name == "" && addr.Address == email
 }
 
-func (u *User) Listusers() (users []User, err error) {
+// checkEmail 有邮箱时检查格式，新建、修改用户时调用，验证邮件不会发到乱填的地址
+func (u *User) checkEmail() error {
+	if u.Email != nil && !ValidEmail(*u.Email) {
+		return ErrInvalidEmail
+	}
+	return nil
+}
 
-	if err = orm.Eloquent.Find(&users).Error; err != nil {
-		return
+// EmailVerified 邮箱是否已验证
+func (u *User) EmailVerified() bool {
+	return u.Email != nil && u.EmailVerifiedAt != nil
+}
+
+// BeforeSave 入库前统一邮箱格式
+func (u *User) BeforeSave() error {
+	if u.Email != nil {
+		u.SetEmail(*u.Email)
 	}
+	return nil
+}
+
+// entry 不为nil时在同一个事务里写审计日志和用户事件，TargetId 自动填新用户的id
+func (u *User) Adduser(entry *AuditLog) (id int, err error) { //user对象的方法 可以直接user.Adduser方法来完成添加记录
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		if err := u.adduser(tx); err != nil {
+			return nil, nil, err
+		}
+		if entry != nil {
+			entry.TargetId = u.Id
+		}
+		return nil, *u, nil
+	})
+	id = u.Id
 	return
+}
 
+// adduser 校验密码后新建用户、加入所属组织、记录密码，可以在事务里用
+func (u *User) adduser(db *gorm.DB) error {
+	if err := password.Default.Validate(u.Password, u.Name); err != nil {
+		return err
+	}
+	if err := u.checkEmail(); err != nil {
+		return err
+	}
+	if err := tenantDB(db, u.TenantId).Create(u).Error; err != nil {
+		return err
+	}
+	if _, err := addMember(db, u.TenantId, u.Id, u.Role); err != nil {
+		return err
+	}
+	return recordPassword(db, u.Id, u.Password)
 }
 
-func (u *User) Login() (user1 User, err error) {
-	obj := orm.Eloquent.Where("name=? and password=?", u.Name, u.Password).First(&user1)
-	if err = obj.Error; err != nil {
-		fmt.Printf("这是登陆错误  %v 和 %T", err, err)
-		return
+// legacyUserIndexes 多租户之前 name、email 上全局唯一的索引，MySQL 和 Postgres 里的名字
+var legacyUserIndexes = []string{"name", "users_name_key", "uix_users_email"}
+
+// DropLegacyUserIndexes 删除多租户之前 name、email 上全局唯一的索引，否则不同组织里不能有同名用户
+// AutoMigrate 只会加索引不会删，启动时在 AutoMigrate 之后调用
+func DropLegacyUserIndexes() (err error) {
+	defer translate(&err)
+	db := orm.Eloquent
+	for _, name := range legacyUserIndexes {
+		if !db.Dialect().HasIndex("users", name) {
+			continue
+		}
+		if db.Dialect().GetName() == "postgres" && name == "users_name_key" { //建表时的 unique 在 Postgres 里是约束
+			err = db.Exec("ALTER TABLE users DROP CONSTRAINT users_name_key").Error
+		} else {
+			err = db.Model(&User{}).RemoveIndex(name).Error
+		}
+		if err != nil {
+			return
+		}
+		log.Println("dropped legacy index", name, "on users")
 	}
-	fmt.Println(user1)
+	return nil
+}
+
+// GetUser 按id查找用户，不区分租户，只给内部使用
+func GetUser(id int) (user User, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.First(&user, id).Error
 	return
+}
 
+// GetTenantUser 在租户内按id查找用户，包括从别的组织加入进来的成员
+func GetTenantUser(tenantId, id int) (user User, err error) {
+	defer translate(&err)
+	err = tenantUsers(tenantId).First(&user, id).Error
+	return
 }
 
-func (user *User) Deleteuser(id int) (Result User, err error) {
-	if err = orm.Eloquent.Select([]string{"id"}).First(&user, id).Error; err != nil {
-		return
+// tenantUsers 组织能看到的用户：在该组织注册的，和通过 memberships 加入该组织的
+// 切换组织后 token 里是加入的组织，不是用户注册时所在的组织
+func tenantUsers(tenantId int) *gorm.DB {
+	if tenantId == 0 {
+		tenantId = DefaultTenantId
 	}
-	if err = orm.Eloquent.Delete(&user).Error; err != nil {
+	return orm.Eloquent.Where("users.tenant_id = ? or users.id in (select user_id from memberships where organization_id = ?)", tenantId, tenantId)
+}
+
+// FindByAccount 在租户内按用户名或邮箱查找用户
+func FindByAccount(tenantId int, account string) (user User, err error) {
+	defer translate(&err)
+	err = Tenant(tenantId).Where("name = ? or email = ?", account, NormalizeEmail(account)).First(&user).Error
+	return
+}
+
+func (u *User) Listusers() (users []User, err error) {
+	defer translate(&err)
+
+	if err = tenantUsers(u.TenantId).Find(&users).Error; err != nil {
 		return
 	}
-	Result = *user
 	return
 
 }
 
-func (user *User) Updatauser(id int) (updatauser User, err error) {
-	if err = orm.Eloquent.Select([]string{"id"}).First(&updatauser, id).Error; err != nil {
+// ListusersAfter 按id分页列出租户内的用户，取id大于 afterId 的最多 limit 个
+func ListusersAfter(tenantId, afterId, limit int) (users []User, err error) {
+	defer translate(&err)
+	err = tenantUsers(tenantId).Where("id > ?", afterId).Order("id asc").Limit(limit).Find(&users).Error
+	return
+}
+
+func (u *User) Login() (user1 User, err error) {
+	defer translate(&err)
+	obj := Tenant(u.TenantId).Where("name=?", u.Name).First(&user1)
+	if err = obj.Error; err != nil {
+		fmt.Printf("这是登陆错误  %v 和 %T", err, err)
+		// 用户不存在时也做一次同样的比较，避免从耗时上判断出用户是否存在
+		subtle.ConstantTimeCompare([]byte(dummyPassword), []byte(u.Password))
 		return
 	}
-	if err = orm.Eloquent.Model(&updatauser).Update(&user).Error; err != nil {
+	// 密码错误时也返回查到的用户，方便记录安全事件
+	if subtle.ConstantTimeCompare([]byte(user1.Password), []byte(u.Password)) != 1 {
+		err = ErrWrongPassword
 		return
 	}
+	fmt.Println(user1)
 	return
 
 }
+
+// Deleteuser 删除用户并吊销该用户所有的会话，version 不为0时只有版本一致才删除
+// entry 不为nil时在同一个事务里写审计日志和用户事件
+func (user *User) Deleteuser(id, version int, entry *AuditLog) (Result User, err error) {
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		if err := tenantDB(tx, user.TenantId).First(&Result, id).Error; err != nil {
+			return nil, nil, err
+		}
+		db := tenantDB(tx, user.TenantId)
+		if version != 0 {
+			db = db.Where("version = ?", version)
+		}
+		result := db.Delete(&User{Id: Result.Id, TenantId: Result.TenantId})
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, nil, ErrStaleVersion
+		}
+		if err := revokeSessions(tx, Result.Id); err != nil { //已经签发的token不能再用
+			return nil, nil, err
+		}
+		return Result, nil, nil
+	})
+	return
+
+}
+
+// Updatefields 按字段修改用户，用于 PUT/PATCH，返回修改后的用户
+// fields 只能包含 name、password、email，email 为 nil 或空字符串时清空；换了邮箱需要重新验证
+// version 不为0时做乐观锁检查，和数据库里的版本不一致返回 ErrStaleVersion
+// 改了密码时吊销该用户所有的会话，包括当前的，需要用新密码重新登录
+// entry 不为nil时在同一个事务里写审计日志和用户事件
+func (user *User) Updatefields(id, version int, fields map[string]interface{}, entry *AuditLog) (updated User, err error) {
+	defer translate(&err)
+	err = auditedTx(entry, func(tx *gorm.DB) (interface{}, interface{}, error) {
+		var before User
+		if err := tenantDB(tx, user.TenantId).First(&before, id).Error; err != nil {
+			return nil, nil, err
+		}
+		if version != 0 && before.Version != version {
+			return nil, nil, ErrStaleVersion
+		}
+		values := map[string]interface{}{}
+		name := before.Name
+		if v, ok := fields["name"].(string); ok {
+			name = v
+			values["name"] = v
+		}
+		if pw, ok := fields["password"].(string); ok {
+			if err := password.Default.Validate(pw, name); err != nil {
+				return nil, nil, err
+			}
+			if err := checkPasswordHistory(tx, before.Id, pw); err != nil {
+				return nil, nil, err
+			}
+			values["password"] = pw
+		}
+		if v, ok := fields["email"]; ok {
+			var email User
+			if s, ok := v.(string); ok {
+				email.SetEmail(s)
+			}
+			if err := email.checkEmail(); err != nil {
+				return nil, nil, err
+			}
+			if email.GetEmail() != before.GetEmail() {
+				values["email"] = email.Email
+				values["email_verified_at"] = nil
+			}
+		}
+		updated = before
+		if len(values) == 0 {
+			return before, updated, nil
+		}
+		db := tenantDB(tx, user.TenantId).Model(&User{Id: before.Id, TenantId: before.TenantId})
+		if version != 0 { //读取之后到更新之间被别人改了也能发现
+			db = db.Where("version = ?", version)
+		}
+		result := db.Updates(values)
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, nil, ErrStaleVersion
+		}
+		if pw, ok := values["password"].(string); ok {
+			if err := recordPassword(tx, before.Id, pw); err != nil {
+				return nil, nil, err
+			}
+			if err := revokeSessions(tx, before.Id); err != nil { //改密码之前签发的token不能再用
+				return nil, nil, err
+			}
+		}
+		updated = User{}
+		if err := tx.First(&updated, before.Id).Error; err != nil { //重新读出版本号等数据库里改的字段
+			return nil, nil, err
+		}
+		return before, updated, nil
+	})
+	return
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	orm "github.com/xdtest/project/database"
+)
+
+const testPassword = "Correct-Horse-42"
+
+// setupDB 用内存里的 sqlite，返回清理函数
+func setupDB(t *testing.T) func() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.DB().SetMaxOpenConns(1) //每个连接是一个单独的内存数据库，事务里再用 orm.Eloquent 会卡住
+	db.AutoMigrate(&User{}, &PasswordHistory{}, &PasswordReset{}, &Session{}, &AuditLog{}, &Organization{},
+		&Membership{}, &UserEvent{}, &Webhook{}, &WebhookDelivery{})
+	prevDB := orm.Eloquent
+	UseDB(db)
+	if err := EnsureDefaultOrganization(); err != nil {
+		t.Fatalf("create default organization: %v", err)
+	}
+	return func() {
+		orm.Eloquent = prevDB
+		db.Close()
+	}
+}
+
+func createUser(t *testing.T, name string) User {
+	u := User{Name: name, Password: testPassword, TenantId: DefaultTenantId, Status: UserActive}
+	if _, err := u.Adduser(nil); err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return u
+}
+
+func newSession(t *testing.T, u User) string {
+	session, err := u.CreateSession("test", "go-test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return session.TokenId
+}
+
+func wantSession(t *testing.T, what, tokenId string, active bool) {
+	t.Helper()
+	_, err := FindActiveSession(tokenId)
+	if active && err != nil {
+		t.Errorf("%s: session revoked (%v), want active", what, err)
+	}
+	if !active && err != ErrSessionRevoked {
+		t.Errorf("%s: FindActiveSession = %v, want %v", what, err, ErrSessionRevoked)
+	}
+}
+
+// 删除用户、改密码后之前签发的token都不能再用
+func TestRevokeSessions(t *testing.T) {
+	defer setupDB(t)()
+	alice := createUser(t, "alice")
+	token := newSession(t, alice)
+	u := User{TenantId: DefaultTenantId}
+
+	updated, err := u.Updatefields(alice.Id, 0, map[string]interface{}{"name": "alice2"}, nil)
+	if err != nil {
+		t.Fatalf("Updatefields name: %v", err)
+	}
+	wantSession(t, "after changing the name", token, true)
+	if _, err := u.Updatefields(alice.Id, updated.Version, map[string]interface{}{"password": "Battery-Staple-77"}, nil); err != nil {
+		t.Fatalf("Updatefields password: %v", err)
+	}
+	wantSession(t, "after changing the password", token, false)
+
+	bob := createUser(t, "bob")
+	token = newSession(t, bob)
+	if _, _, err := ImportUsers(DefaultTenantId, []User{{Name: "bob", Role: ImportKeepRole, Status: UserActive}}, ImportUpsert, false, nil); err != nil {
+		t.Fatalf("import without password: %v", err)
+	}
+	wantSession(t, "after importing without a password", token, true)
+	results, _, err := ImportUsers(DefaultTenantId, []User{{Name: "bob", Password: "Battery-Staple-77", Role: ImportKeepRole}}, ImportUpsert, false, nil)
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("import with a password: %v, %+v", err, results)
+	}
+	wantSession(t, "after importing a new password", token, false)
+
+	carol := createUser(t, "carol")
+	token = newSession(t, carol)
+	if _, err := (&User{Id: carol.Id, TenantId: DefaultTenantId}).Deleteuser(carol.Id, 0, nil); err != nil {
+		t.Fatalf("Deleteuser: %v", err)
+	}
+	wantSession(t, "after deleting the user", token, false)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// ErrStaleVersion 乐观锁冲突，记录在读取之后已经被别人修改
+var ErrStaleVersion = errors.New("record was modified by someone else")
+
+// 有 Version 字段的表，每次按字段更新时自动 version = version + 1
+func init() {
+	registerVersionCallbacks(orm.Eloquent)
+}
+
+func registerVersionCallbacks(db *gorm.DB) {
+	db.Callback().Update().Before("gorm:update").Register("version:bump", versionBump)
+}
+
+func versionBump(scope *gorm.Scope) {
+	field, ok := scope.FieldByName("Version")
+	if !ok {
+		return
+	}
+	attrs, ok := scope.InstanceGet("gorm:update_attrs")
+	if !ok {
+		return
+	}
+	updateAttrs := attrs.(map[string]interface{})
+	if len(updateAttrs) == 0 {
+		return
+	}
+	updateAttrs[field.DBName] = gorm.Expr(scope.Quote(field.DBName) + " + 1")
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	orm "github.com/xdtest/project/database"
+)
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"   //等待发送或等待重试
+	DeliverySucceeded = "succeeded" //对方返回了2xx
+	DeliveryDead      = "dead"      //重试次数用完或订阅已删除、停用，不再发送，可以手动重发
+)
+
+// Webhook 一个 webhook 订阅，组织里的用户事件发生时 POST 到 URL
+type Webhook struct {
+	Id        int       `json:"id" gorm:"PRIMARY_KEY"`
+	TenantId  int       `json:"tenant_id" gorm:"index;not null"`
+	URL       string    `json:"url" gorm:"column:url;type:varchar(2048);not null"`
+	Events    string    `json:"events" gorm:"type:varchar(255)"` //逗号分隔的事件类型，空表示全部
+	Secret    string    `json:"-" gorm:"type:varchar(128);not null"`
+	Active    bool      `json:"active" gorm:"not null;default:true"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Subscribes 是否订阅了这种事件
+func (w *Webhook) Subscribes(eventType string) bool {
+	if w.Events == "" {
+		return true
+	}
+	for _, t := range strings.Split(w.Events, ",") {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery 一次投递，也是订阅的投递记录；重发时新建一条，原来的记录保留
+type WebhookDelivery struct {
+	Id             int        `json:"id" gorm:"PRIMARY_KEY"`
+	WebhookId      int        `json:"webhook_id" gorm:"index;not null"`
+	EventId        int        `json:"event_id"`
+	EventType      string     `json:"event_type" gorm:"type:varchar(32)"`
+	Payload        string     `json:"-" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"type:varchar(16);not null;index:idx_webhook_deliveries_due"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_due"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error" gorm:"type:varchar(512)"`
+	LastResponse   string     `json:"-" gorm:"type:text"` //对方返回内容的开头部分，只在库里排查用，不通过接口返回
+	LastDuration   int        `json:"last_duration_ms"`
+	RedeliveryOf   int        `json:"redelivery_of"` //手动重发时原来那次投递的id
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// CreateWebhook 新建订阅，没有指定 Secret 时随机生成一个
+func (w *Webhook) CreateWebhook() (err error) {
+	defer translate(&err)
+	if w.Secret == "" {
+		if w.Secret, err = NewRandomToken(); err != nil {
+			return
+		}
+	}
+	return orm.Eloquent.Create(w).Error
+}
+
+// ListWebhooks 组织的所有订阅
+func ListWebhooks(tenantId int) (list []Webhook, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.Where("tenant_id = ?", tenantId).Order("id").Find(&list).Error
+	return
+}
+
+// GetWebhook 取组织里的订阅
+func GetWebhook(tenantId, id int) (w Webhook, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.Where("tenant_id = ? and id = ?", tenantId, id).First(&w).Error
+	return
+}
+
+// FindWebhook 按id取订阅，不限组织，发送投递时用
+func FindWebhook(id int) (w Webhook, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.First(&w, id).Error
+	return
+}
+
+// UpdateWebhook 修改订阅的 url、events、active
+func UpdateWebhook(tenantId, id int, fields map[string]interface{}) (w Webhook, err error) {
+	defer translate(&err)
+	if err = orm.Eloquent.Where("tenant_id = ? and id = ?", tenantId, id).First(&w).Error; err != nil {
+		return
+	}
+	err = orm.Eloquent.Model(&w).Updates(fields).Error
+	return
+}
+
+// DeleteWebhook 删除订阅和它的投递记录
+func DeleteWebhook(tenantId, id int) (err error) {
+	defer translate(&err)
+	tx := orm.Eloquent.Begin()
+	result := tx.Where("tenant_id = ? and id = ?", tenantId, id).Delete(&Webhook{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return gorm.ErrRecordNotFound
+	}
+	if err = tx.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit().Error
+}
+
+// enqueueWebhookDeliveries 给订阅了这个事件的 webhook 各加一条待发送的投递，和事件在同一个事务里
+func enqueueWebhookDeliveries(db *gorm.DB, event *UserEvent) error {
+	var hooks []Webhook
+	if err := db.Where("tenant_id = ? and active = ?", event.TenantId, true).Find(&hooks).Error; err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(event.Message())
+	for _, w := range hooks {
+		if !w.Subscribes(event.Type) {
+			continue
+		}
+		d := WebhookDelivery{
+			WebhookId:     w.Id,
+			EventId:       event.Id,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: event.CreatedAt,
+		}
+		if err := db.Create(&d).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DueDeliveries 到了发送时间的投递
+func DueDeliveries(limit int) (list []WebhookDelivery, err error) {
+	defer translate(&err)
+	err = orm.Eloquent.Where("status = ? and next_attempt_at <= ?", DeliveryPending, time.Now()).
+		Order("next_attempt_at").Limit(limit).Find(&list).Error
+	return
+}
+
+// ClaimDelivery 发送前先把下次发送时间推到 lease 之后，多个进程同时取到时只有一个能领到
+func ClaimDelivery(d *WebhookDelivery, lease time.Duration) (ok bool, err error) {
+	defer translate(&err)
+	next := time.Now().Add(lease)
+	result := orm.Eloquent.Model(&WebhookDelivery{}).
+		Where("id = ? and status = ? and attempts = ? and next_attempt_at <= ?", d.Id, DeliveryPending, d.Attempts, time.Now()).
+		Update("next_attempt_at", next)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	d.NextAttemptAt = next
+	return result.RowsAffected == 1, nil
+}
+
+// SaveDeliveryAttempt 保存一次发送的结果，状态和下次发送时间由调用方算好
+func SaveDeliveryAttempt(d *WebhookDelivery) (err error) {
+	defer translate(&err)
+	return orm.Eloquent.Model(d).Updates(map[string]interface{}{
+		"status":           d.Status,
+		"attempts":         d.Attempts,
+		"next_attempt_at":  d.NextAttemptAt,
+		"last_attempt_at":  d.LastAttemptAt,
+		"last_status_code": d.LastStatusCode,
+		"last_error":       d.LastError,
+		"last_response":    d.LastResponse,
+		"last_duration":    d.LastDuration,
+	}).Error
+}
+
+// ListDeliveries 订阅的投递记录，最新的在前
+func ListDeliveries(webhookId, limit, offset int) (list []WebhookDelivery, err error) {
+	defer translate(&err)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	err = orm.Eloquent.Where("webhook_id = ?", webhookId).Order("id desc").Limit(limit).Offset(offset).Find(&list).Error
+	return
+}
+
+// Redeliver 用原来的内容新建一条投递，马上发送
+func Redeliver(webhookId, deliveryId int) (d WebhookDelivery, err error) {
+	defer translate(&err)
+	var orig WebhookDelivery
+	if err = orm.Eloquent.Where("webhook_id = ? and id = ?", webhookId, deliveryId).First(&orig).Error; err != nil {
+		return
+	}
+	d = WebhookDelivery{
+		WebhookId:     orig.WebhookId,
+		EventId:       orig.EventId,
+		EventType:     orig.EventType,
+		Payload:       orig.Payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  orig.Id,
+	}
+	err = orm.Eloquent.Create(&d).Error
+	return
+}
@@ -0,0 +1,251 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Param 查询参数或表单字段
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Operation 一个接口的说明，和路由注册写在一起
+type Operation struct {
+	Summary    string
+	Tags       []string
+	Auth       bool        // 需要token，/v1 下的接口默认需要
+	Deprecated bool        // 旧接口
+	Query      []Param     // 查询参数
+	Headers    []Param     // 请求头，如 If-Match
+	Form       []Param     // application/x-www-form-urlencoded 表单
+	Body       interface{} // JSON请求体的类型
+	BodyType   string      // JSON请求体的Content-Type，默认 application/json
+	Response   interface{} // 成功时 data 字段的类型，nil 表示没有 data
+	Status     int         // 成功时的状态码，默认200
+	Formats    []string    // 除JSON外请求体和响应还支持的格式，如 application/x-protobuf
+}
+
+// Docs 接口说明，key 为 "METHOD /path"，path 和 gin 注册时的写法一致
+type Docs map[string]Operation
+
+// Info 文档标题和版本
+var Info = map[string]string{
+	"title":   "project API",
+	"version": "1.0.0",
+}
+
+// Document 生成的 OpenAPI 3 文档
+type Document struct {
+	OpenAPI    string                            `json:"openapi"`
+	Info       map[string]string                 `json:"info"`
+	Paths      map[string]map[string]*jsonOp     `json:"paths"`
+	Components map[string]map[string]interface{} `json:"components"`
+	schemas    schemas
+}
+
+type jsonOp struct {
+	Summary     string                `json:"summary,omitempty"`
+	OperationId string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []jsonParam           `json:"parameters,omitempty"`
+	RequestBody *jsonBody             `json:"requestBody,omitempty"`
+	Responses   map[string]jsonBody   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type jsonParam struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type jsonBody struct {
+	Description string                        `json:"description,omitempty"`
+	Required    bool                          `json:"required,omitempty"`
+	Content     map[string]map[string]*Schema `json:"content,omitempty"`
+}
+
+// Build 按实际注册的路由生成文档，没有写说明的路由不会出现在文档里
+func Build(routes gin.RoutesInfo, docs Docs) *Document {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info,
+		Paths:   map[string]map[string]*jsonOp{},
+		schemas: schemas{},
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path+routes[i].Method < routes[j].Path+routes[j].Method
+	})
+	for _, r := range routes {
+		op, ok := docs[r.Method+" "+r.Path]
+		if !ok {
+			continue
+		}
+		path, params := convertPath(r.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*jsonOp{}
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = doc.operation(r, op, params)
+	}
+	schemaMap := map[string]interface{}{}
+	for name, s := range doc.schemas {
+		schemaMap[name] = s
+	}
+	schemaMap["Error"] = &Schema{Type: "object", Properties: map[string]*Schema{
+		"status":     {Type: "integer", Description: "0 成功，-1 失败"},
+		"msg":        {Type: "string"},
+		"request_id": {Type: "string"},
+	}}
+	doc.Components = map[string]map[string]interface{}{
+		"schemas": schemaMap,
+		"securitySchemes": {
+			"bearerAuth": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+		},
+	}
+	return doc
+}
+
+// Has 文档里有没有这个路由，path 用 gin 的写法
+func (d *Document) Has(method, path string) bool {
+	p, _ := convertPath(path)
+	_, ok := d.Paths[p][strings.ToLower(method)]
+	return ok
+}
+
+func (d *Document) operation(r gin.RouteInfo, op Operation, params []jsonParam) *jsonOp {
+	out := &jsonOp{
+		Summary:     op.Summary,
+		OperationId: operationId(r),
+		Tags:        op.Tags,
+		Deprecated:  op.Deprecated,
+		Parameters:  params,
+		Responses:   map[string]jsonBody{},
+	}
+	for _, p := range op.Query {
+		out.Parameters = append(out.Parameters, jsonParam{
+			Name: p.Name, In: "query", Description: p.Description, Required: p.Required,
+			Schema: &Schema{Type: "string"},
+		})
+	}
+	for _, p := range op.Headers {
+		out.Parameters = append(out.Parameters, jsonParam{
+			Name: p.Name, In: "header", Description: p.Description, Required: p.Required,
+			Schema: &Schema{Type: "string"},
+		})
+	}
+	if len(op.Form) > 0 {
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, p := range op.Form {
+			form.Properties[p.Name] = &Schema{Type: "string", Description: p.Description}
+			if p.Required {
+				form.Required = append(form.Required, p.Name)
+			}
+		}
+		out.RequestBody = &jsonBody{Required: true, Content: map[string]map[string]*Schema{
+			"application/x-www-form-urlencoded": {"schema": form},
+		}}
+	} else if op.Body != nil {
+		bodyType := op.BodyType
+		if bodyType == "" {
+			bodyType = "application/json"
+		}
+		out.RequestBody = &jsonBody{Required: true, Content: map[string]map[string]*Schema{
+			bodyType: {"schema": d.schemas.of(op.Body)},
+		}}
+		addFormats(out.RequestBody.Content, op.Formats, out.RequestBody.Content[bodyType]["schema"])
+	}
+	if op.Auth || strings.HasPrefix(r.Path, "/v1/") {
+		out.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := jsonBody{Description: http.StatusText(status)}
+	if status != http.StatusNoContent {
+		envelope := &Schema{Type: "object", Properties: map[string]*Schema{
+			"status": {Type: "integer"},
+			"msg":    {Type: "string"},
+		}}
+		if data := d.schemas.of(op.Response); data != nil {
+			envelope.Properties["data"] = data
+		}
+		ok.Content = map[string]map[string]*Schema{"application/json": {"schema": envelope}}
+		addFormats(ok.Content, op.Formats, envelope)
+	}
+	out.Responses[strconv.Itoa(status)] = ok
+	out.Responses["default"] = jsonBody{
+		Description: "错误",
+		Content: map[string]map[string]*Schema{
+			"application/json": {"schema": {Ref: "#/components/schemas/Error"}},
+		},
+	}
+	return out
+}
+
+// addFormats 其它格式和JSON的结构一样，Protobuf 是二进制，消息定义见 pb/*.proto
+func addFormats(content map[string]map[string]*Schema, formats []string, schema *Schema) {
+	for _, f := range formats {
+		if strings.Contains(f, "protobuf") {
+			content[f] = map[string]*Schema{"schema": {Type: "string", Format: "binary", Description: "见 pb/*.proto"}}
+			continue
+		}
+		content[f] = map[string]*Schema{"schema": schema}
+	}
+}
+
+// convertPath 把 gin 的 /users/:id 转成 /users/{id}，并返回路径参数
+func convertPath(path string) (string, []jsonParam) {
+	var params []jsonParam
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if p == "" || (p[0] != ':' && p[0] != '*') {
+			continue
+		}
+		params = append(params, jsonParam{Name: p[1:], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		parts[i] = "{" + p[1:] + "}"
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// operationId 用 handler 的函数名，如 github.com/xdtest/project/apis.Showuser -> Showuser
+// 匿名函数没有名字，用方法和路径拼一个
+func operationId(r gin.RouteInfo) string {
+	name := r.Handler
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	if !strings.HasPrefix(name, "func") {
+		return strings.TrimSuffix(name, "-fm")
+	}
+	name = strings.ToLower(r.Method)
+	for _, part := range strings.FieldsFunc(r.Path, func(c rune) bool {
+		return c == '/' || c == ':' || c == '*' || c == '-' || c == '.' || c == '_'
+	}) {
+		name += strings.Title(part)
+	}
+	return name
+}
+
+// Handler 返回 /openapi.json 的 handler，第一次请求时按路由生成
+func Handler(router *gin.Engine, docs Docs) gin.HandlerFunc {
+	var once sync.Once
+	var doc *Document
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = Build(router.Routes(), docs)
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
@@ -0,0 +1,50 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+)
+
+// Swagger UI 的静态文件编译在程序里，内网环境也能打开
+var uiPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="swagger-ui-bundle.js"></script>
+<script src="swagger-ui-standalone-preset.js"></script>
+<script>
+window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: {{.URL}},
+    dom_id: "#swagger-ui",
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    layout: "StandaloneLayout"
+  });
+};
+</script>
+</body>
+</html>
+`))
+
+// UI 返回 Swagger UI 的 handler，注册到 /docs/*any，specURL 是 openapi.json 的地址
+func UI(specURL string) gin.HandlerFunc {
+	files := http.FileServer(swaggerFiles.HTTP)
+	return func(c *gin.Context) {
+		file := c.Param("any")
+		if file == "" || file == "/" || file == "/index.html" {
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			uiPage.Execute(c.Writer, map[string]string{"Title": Info["title"], "URL": specURL})
+			return
+		}
+		c.Request.URL.Path = file
+		files.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,66 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedList 本地的泄露密码库，不需要联网
+// 按 k-anonymity 的方式存放：对密码做SHA1，取前5位作为文件名，
+// 文件里每行是剩下的35位和出现次数，格式 SUFFIX:COUNT（和 Pwned Passwords range 接口一致）
+type BreachedList struct {
+	Dir string
+}
+
+// NewBreachedList 指定存放前缀文件的目录
+func NewBreachedList(dir string) *BreachedList {
+	return &BreachedList{Dir: dir}
+}
+
+// Check 检查目录是否存在，目录不存在时 Contains 总是返回 false，等于没有检查
+func (b *BreachedList) Check() error {
+	info, err := os.Stat(b.Dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", b.Dir)
+	}
+	return nil
+}
+
+// Contains 判断密码是否在泄露库中，对应前缀文件不存在时认为不在
+func (b *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(b.Dir, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
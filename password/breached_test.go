@@ -0,0 +1,83 @@
+package password
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newBreachedDir 建一个只有 5BAA6 前缀文件的目录，用完要删除
+// "password" 的 SHA1 是 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+func newBreachedDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "breached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\r\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "5BAA6"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestBreachedListContains(t *testing.T) {
+	dir := newBreachedDir(t)
+	defer os.RemoveAll(dir)
+	b := NewBreachedList(dir)
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},       // 前缀文件里有，后缀大小写不同也能匹配
+		{"password1", false},     // 前缀文件不存在
+		{"correct horse", false}, // 前缀文件不存在
+	}
+	for _, tt := range tests {
+		got, err := b.Contains(tt.password)
+		if err != nil {
+			t.Fatalf("Contains(%q) error: %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestBreachedListTxtSuffix(t *testing.T) {
+	dir := newBreachedDir(t)
+	defer os.RemoveAll(dir)
+	if err := os.Rename(filepath.Join(dir, "5BAA6"), filepath.Join(dir, "5BAA6.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewBreachedList(dir).Contains("password"); err != nil || !got {
+		t.Fatalf("Contains(password) = %v, %v; want true", got, err)
+	}
+}
+
+func TestBreachedListCheck(t *testing.T) {
+	dir := newBreachedDir(t)
+	defer os.RemoveAll(dir)
+	if err := NewBreachedList(dir).Check(); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	missing := NewBreachedList(filepath.Join(os.TempDir(), "no-such-breached-dir"))
+	if err := missing.Check(); err == nil {
+		t.Fatal("Check() on a missing directory = nil, want error")
+	}
+	if got, err := missing.Contains("password"); err != nil || got {
+		t.Fatalf("Contains on a missing directory = %v, %v; want false, nil", got, err)
+	}
+}
+
+func TestValidateRejectsBreached(t *testing.T) {
+	dir := newBreachedDir(t)
+	defer os.RemoveAll(dir)
+	p := &Policy{MinLength: 8, Breached: NewBreachedList(dir)}
+	if err := p.Validate("password", ""); err == nil {
+		t.Fatal("Validate(password) = nil, want breached error")
+	}
+	if err := p.Validate("password1", ""); err != nil {
+		t.Fatalf("Validate(password1) = %v", err)
+	}
+}
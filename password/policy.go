@@ -0,0 +1,158 @@
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy 密码策略
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MinClasses    int     // 大写、小写、数字、符号至少包含几类
+	MaxSimilarity float64 // 和用户名的相似度上限，0-1，0表示不检查
+	HistorySize   int     // 不能和最近几次用过的密码相同，0表示不检查
+	Breached      *BreachedList
+}
+
+// Default 全局使用的密码策略
+var Default = &Policy{
+	MinLength:     8,
+	MaxLength:     72,
+	MinClasses:    2,
+	MaxSimilarity: 0.7,
+	HistorySize:   5,
+	Breached:      NewBreachedList("data/breached"),
+}
+
+// PolicyError 不满足策略时返回，列出所有不满足的项
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return strings.Join(e.Violations, "；")
+}
+
+// Validate 检查密码是否满足策略，username用来做相似度检查
+func (p *Policy) Validate(password, username string) error {
+	var violations []string
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, "密码长度不能少于"+strconv.Itoa(p.MinLength)+"位")
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, "密码长度不能超过"+strconv.Itoa(p.MaxLength)+"位")
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "密码必须包含小写字母")
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "密码必须包含大写字母")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "密码必须包含数字")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "密码必须包含符号")
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		violations = append(violations, "密码至少要包含大写字母、小写字母、数字、符号中的"+strconv.Itoa(p.MinClasses)+"类")
+	}
+
+	if p.MaxSimilarity > 0 && username != "" && tooSimilar(password, username, p.MaxSimilarity) {
+		violations = append(violations, "密码不能和用户名太相似")
+	}
+
+	if p.Breached != nil && len(violations) == 0 {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, "该密码已出现在泄露的密码库中，请换一个")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// tooSimilar 密码包含用户名、用户名包含密码，或者编辑距离算出的相似度超过上限
+func tooSimilar(password, username string, max float64) bool {
+	p := strings.ToLower(password)
+	u := strings.ToLower(username)
+	if utf8.RuneCountInString(u) >= 3 && strings.Contains(p, u) {
+		return true
+	}
+	if utf8.RuneCountInString(p) >= 3 && strings.Contains(u, p) {
+		return true
+	}
+	a, b := []rune(p), []rune(u)
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return false
+	}
+	return 1-float64(levenshtein(a, b))/float64(longest) >= max
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	p := &Policy{MinLength: 8, MaxLength: 20, MinClasses: 2, MaxSimilarity: 0.7}
+	tests := []struct {
+		password, username string
+		want               []string // 期望出现的提示，nil 表示通过
+	}{
+		{"abcd1234", "bob", nil},
+		{"Ab1!", "bob", []string{"不能少于8位"}},
+		{strings.Repeat("a1", 11), "bob", []string{"不能超过20位"}},
+		{"abcdefgh", "bob", []string{"中的2类"}},
+		{"alice2024", "alice", []string{"和用户名太相似"}},
+		{"密码密码密码12", "bob", nil}, // 按字符而不是字节计算长度
+		{"短1", "bob", []string{"不能少于8位"}},
+	}
+	for _, tt := range tests {
+		err := p.Validate(tt.password, tt.username)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("Validate(%q, %q) = %v, want nil", tt.password, tt.username, err)
+			}
+			continue
+		}
+		perr, ok := err.(*PolicyError)
+		if !ok {
+			t.Errorf("Validate(%q, %q) = %v, want *PolicyError", tt.password, tt.username, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(perr.Error(), w) {
+				t.Errorf("Validate(%q, %q) = %q, want it to contain %q", tt.password, tt.username, perr.Error(), w)
+			}
+		}
+	}
+}
+
+func TestValidateRequiredClasses(t *testing.T) {
+	p := &Policy{MinLength: 1, RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+	err := p.Validate("abc", "")
+	perr, ok := err.(*PolicyError)
+	if !ok {
+		t.Fatalf("Validate = %v, want *PolicyError", err)
+	}
+	if len(perr.Violations) != 3 {
+		t.Fatalf("violations = %v, want upper, digit and symbol", perr.Violations)
+	}
+	if err := p.Validate("aB3$", ""); err != nil {
+		t.Fatalf("Validate(aB3$) = %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"张三", "张四", 1},
+		{"same", "same", 0},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTooSimilar(t *testing.T) {
+	tests := []struct {
+		password, username string
+		want               bool
+	}{
+		{"Alice123", "alice", true}, // 包含用户名，不区分大小写
+		{"ali", "alice", true},      // 用户名包含密码
+		{"alicf", "alice", true},    // 编辑距离1，相似度0.8
+		{"x9$kq2Lm", "alice", false},
+		{"ab", "al", false}, // 太短不算包含
+	}
+	for _, tt := range tests {
+		if got := tooSimilar(tt.password, tt.username, 0.7); got != tt.want {
+			t.Errorf("tooSimilar(%q, %q) = %v, want %v", tt.password, tt.username, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package pb 是 user.proto、error.proto 生成的 Protobuf 消息，修改 .proto 后重新生成
+package pb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. error.proto user.proto user_service.proto
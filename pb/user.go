@@ -0,0 +1,51 @@
+package pb
+
+import (
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/xdtest/project/models"
+)
+
+// NewUser 把用户转成 Protobuf 消息，不包含密码，HTTP 和 gRPC 共用
+func NewUser(u models.User) *User {
+	m := &User{
+		Id:       int64(u.Id),
+		Name:     u.Name,
+		Role:     int32(u.Role),
+		TenantId: int64(u.TenantId),
+		Status:   u.Status,
+		Version:  int32(u.Version),
+	}
+	if u.Email != nil {
+		m.Email = &wrappers.StringValue{Value: *u.Email}
+	}
+	if u.EmailVerifiedAt != nil {
+		m.EmailVerifiedAt, _ = ptypes.TimestampProto(*u.EmailVerifiedAt)
+	}
+	return m
+}
+
+// NewUserList 用户列表
+func NewUserList(users []models.User) *UserList {
+	list := &UserList{Users: make([]*User, 0, len(users))}
+	for _, u := range users {
+		list.Users = append(list.Users, NewUser(u))
+	}
+	return list
+}
+
+// Fields 转成和JSON请求体一样的字段，传给 models.User.Updatefields
+// 没设置的字段不出现，email 为空字符串表示清空
+func (m *UserInput) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if m.GetName() != nil {
+		fields["name"] = m.Name.Value
+	}
+	if m.GetPassword() != nil {
+		fields["password"] = m.Password.Value
+	}
+	if m.GetEmail() != nil {
+		fields["email"] = m.Email.Value
+	}
+	return fields
+}
@@ -0,0 +1,370 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: user.proto
+
+package pb
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// User 对外返回的用户，不包含密码，对应 apis.UserView
+type User struct {
+	Id                   int64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string                `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email                *wrappers.StringValue `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	EmailVerifiedAt      *timestamp.Timestamp  `protobuf:"bytes,4,opt,name=email_verified_at,json=emailVerifiedAt,proto3" json:"email_verified_at,omitempty"`
+	Role                 int32                 `protobuf:"varint,5,opt,name=role,proto3" json:"role,omitempty"`
+	TenantId             int64                 `protobuf:"varint,6,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Status               string                `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	Version              int32                 `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *User) Reset()         { *m = User{} }
+func (m *User) String() string { return proto.CompactTextString(m) }
+func (*User) ProtoMessage()    {}
+func (*User) Descriptor() ([]byte, []int) {
+	return fileDescriptor_116e343673f7ffaf, []int{0}
+}
+
+func (m *User) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_User.Unmarshal(m, b)
+}
+func (m *User) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_User.Marshal(b, m, deterministic)
+}
+func (m *User) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_User.Merge(m, src)
+}
+func (m *User) XXX_Size() int {
+	return xxx_messageInfo_User.Size(m)
+}
+func (m *User) XXX_DiscardUnknown() {
+	xxx_messageInfo_User.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_User proto.InternalMessageInfo
+
+func (m *User) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *User) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *User) GetEmail() *wrappers.StringValue {
+	if m != nil {
+		return m.Email
+	}
+	return nil
+}
+
+func (m *User) GetEmailVerifiedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.EmailVerifiedAt
+	}
+	return nil
+}
+
+func (m *User) GetRole() int32 {
+	if m != nil {
+		return m.Role
+	}
+	return 0
+}
+
+func (m *User) GetTenantId() int64 {
+	if m != nil {
+		return m.TenantId
+	}
+	return 0
+}
+
+func (m *User) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *User) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+// UserList GET /v1/users 的响应
+type UserList struct {
+	Users                []*User  `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UserList) Reset()         { *m = UserList{} }
+func (m *UserList) String() string { return proto.CompactTextString(m) }
+func (*UserList) ProtoMessage()    {}
+func (*UserList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_116e343673f7ffaf, []int{1}
+}
+
+func (m *UserList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UserList.Unmarshal(m, b)
+}
+func (m *UserList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UserList.Marshal(b, m, deterministic)
+}
+func (m *UserList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UserList.Merge(m, src)
+}
+func (m *UserList) XXX_Size() int {
+	return xxx_messageInfo_UserList.Size(m)
+}
+func (m *UserList) XXX_DiscardUnknown() {
+	xxx_messageInfo_UserList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UserList proto.InternalMessageInfo
+
+func (m *UserList) GetUsers() []*User {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
+// UserInput POST/PUT/PATCH /v1/users 的请求体
+// 没设置的字段表示不修改，email 设置成空字符串表示清空
+type UserInput struct {
+	Name                 *wrappers.StringValue `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Password             *wrappers.StringValue `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	Email                *wrappers.StringValue `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *UserInput) Reset()         { *m = UserInput{} }
+func (m *UserInput) String() string { return proto.CompactTextString(m) }
+func (*UserInput) ProtoMessage()    {}
+func (*UserInput) Descriptor() ([]byte, []int) {
+	return fileDescriptor_116e343673f7ffaf, []int{2}
+}
+
+func (m *UserInput) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UserInput.Unmarshal(m, b)
+}
+func (m *UserInput) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UserInput.Marshal(b, m, deterministic)
+}
+func (m *UserInput) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UserInput.Merge(m, src)
+}
+func (m *UserInput) XXX_Size() int {
+	return xxx_messageInfo_UserInput.Size(m)
+}
+func (m *UserInput) XXX_DiscardUnknown() {
+	xxx_messageInfo_UserInput.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UserInput proto.InternalMessageInfo
+
+func (m *UserInput) GetName() *wrappers.StringValue {
+	if m != nil {
+		return m.Name
+	}
+	return nil
+}
+
+func (m *UserInput) GetPassword() *wrappers.StringValue {
+	if m != nil {
+		return m.Password
+	}
+	return nil
+}
+
+func (m *UserInput) GetEmail() *wrappers.StringValue {
+	if m != nil {
+		return m.Email
+	}
+	return nil
+}
+
+// LoginRequest POST /login 和 gRPC Login 的请求体
+type LoginRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	Tenant               string   `protobuf:"bytes,3,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	DeviceName           string   `protobuf:"bytes,4,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoginRequest) Reset()         { *m = LoginRequest{} }
+func (m *LoginRequest) String() string { return proto.CompactTextString(m) }
+func (*LoginRequest) ProtoMessage()    {}
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_116e343673f7ffaf, []int{3}
+}
+
+func (m *LoginRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoginRequest.Unmarshal(m, b)
+}
+func (m *LoginRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoginRequest.Marshal(b, m, deterministic)
+}
+func (m *LoginRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoginRequest.Merge(m, src)
+}
+func (m *LoginRequest) XXX_Size() int {
+	return xxx_messageInfo_LoginRequest.Size(m)
+}
+func (m *LoginRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoginRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoginRequest proto.InternalMessageInfo
+
+func (m *LoginRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetDeviceName() string {
+	if m != nil {
+		return m.DeviceName
+	}
+	return ""
+}
+
+// Token 登录成功的响应
+type Token struct {
+	User                 *User    `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Token) Reset()         { *m = Token{} }
+func (m *Token) String() string { return proto.CompactTextString(m) }
+func (*Token) ProtoMessage()    {}
+func (*Token) Descriptor() ([]byte, []int) {
+	return fileDescriptor_116e343673f7ffaf, []int{4}
+}
+
+func (m *Token) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Token.Unmarshal(m, b)
+}
+func (m *Token) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Token.Marshal(b, m, deterministic)
+}
+func (m *Token) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Token.Merge(m, src)
+}
+func (m *Token) XXX_Size() int {
+	return xxx_messageInfo_Token.Size(m)
+}
+func (m *Token) XXX_DiscardUnknown() {
+	xxx_messageInfo_Token.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Token proto.InternalMessageInfo
+
+func (m *Token) GetUser() *User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *Token) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*User)(nil), "project.v1.User")
+	proto.RegisterType((*UserList)(nil), "project.v1.UserList")
+	proto.RegisterType((*UserInput)(nil), "project.v1.UserInput")
+	proto.RegisterType((*LoginRequest)(nil), "project.v1.LoginRequest")
+	proto.RegisterType((*Token)(nil), "project.v1.Token")
+}
+
+func init() { proto.RegisterFile("user.proto", fileDescriptor_116e343673f7ffaf) }
+
+var fileDescriptor_116e343673f7ffaf = []byte{
+	// 440 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x91, 0xcf, 0x8e, 0xd3, 0x30,
+	0x10, 0xc6, 0x95, 0x34, 0xe9, 0x36, 0x53, 0xc4, 0x1f, 0x0b, 0xad, 0xac, 0x82, 0x68, 0x88, 0x10,
+	0xca, 0x29, 0x81, 0x72, 0x41, 0xe2, 0x04, 0x48, 0x48, 0x2b, 0xad, 0x38, 0x84, 0x65, 0x0f, 0x5c,
+	0x2a, 0xa7, 0x99, 0x0d, 0x86, 0x26, 0x0e, 0xf6, 0xa4, 0xe5, 0x15, 0x78, 0x16, 0x5e, 0x12, 0xc5,
+	0x4e, 0x61, 0xb5, 0x7b, 0xa9, 0xb8, 0xcd, 0x78, 0xbe, 0xf9, 0xfc, 0xf9, 0x67, 0x80, 0xde, 0xa0,
+	0xce, 0x3a, 0xad, 0x48, 0x31, 0xe8, 0xb4, 0xfa, 0x86, 0x1b, 0xca, 0x76, 0x2f, 0x17, 0xcb, 0x5a,
+	0xa9, 0x7a, 0x8b, 0xb9, 0x9d, 0x94, 0xfd, 0x55, 0x4e, 0xb2, 0x41, 0x43, 0xa2, 0xe9, 0x9c, 0x78,
+	0xf1, 0xe4, 0xa6, 0x60, 0xaf, 0x45, 0xd7, 0xa1, 0x36, 0x6e, 0x9e, 0xfc, 0xf2, 0x21, 0xf8, 0x6c,
+	0x50, 0xb3, 0xbb, 0xe0, 0xcb, 0x8a, 0x7b, 0xb1, 0x97, 0x4e, 0x0a, 0x5f, 0x56, 0x8c, 0x41, 0xd0,
+	0x8a, 0x06, 0xb9, 0x1f, 0x7b, 0x69, 0x54, 0xd8, 0x9a, 0xad, 0x20, 0xc4, 0x46, 0xc8, 0x2d, 0x9f,
+	0xc4, 0x5e, 0x3a, 0x5f, 0x3d, 0xce, 0x9c, 0x79, 0x76, 0x30, 0xcf, 0x3e, 0x91, 0x96, 0x6d, 0x7d,
+	0x29, 0xb6, 0x3d, 0x16, 0x4e, 0xca, 0x3e, 0xc0, 0x03, 0x5b, 0xac, 0x77, 0xa8, 0xe5, 0x95, 0xc4,
+	0x6a, 0x2d, 0x88, 0x07, 0x76, 0x7f, 0x71, 0x6b, 0xff, 0xe2, 0x90, 0xbe, 0xb8, 0x67, 0x97, 0x2e,
+	0xc7, 0x9d, 0xb7, 0x34, 0xe4, 0xd1, 0x6a, 0x8b, 0x3c, 0x8c, 0xbd, 0x34, 0x2c, 0x6c, 0xcd, 0x1e,
+	0x41, 0x44, 0xd8, 0x8a, 0x96, 0xd6, 0xb2, 0xe2, 0x53, 0x1b, 0x7d, 0xe6, 0x0e, 0xce, 0x2a, 0x76,
+	0x0a, 0x53, 0x43, 0x82, 0x7a, 0xc3, 0x4f, 0xec, 0x13, 0xc6, 0x8e, 0x71, 0x38, 0xd9, 0xa1, 0x36,
+	0x52, 0xb5, 0x7c, 0x66, 0xbd, 0x0e, 0x6d, 0xb2, 0x82, 0xd9, 0x80, 0xe2, 0x5c, 0x1a, 0x62, 0xcf,
+	0x21, 0x1c, 0x90, 0x1b, 0xee, 0xc5, 0x93, 0x74, 0xbe, 0xba, 0x9f, 0xfd, 0x83, 0x9e, 0x0d, 0xa2,
+	0xc2, 0x8d, 0x93, 0xdf, 0x1e, 0x44, 0x43, 0x7f, 0xd6, 0x76, 0x3d, 0xb1, 0x17, 0x23, 0x34, 0xef,
+	0x08, 0x3e, 0x0e, 0xe9, 0x6b, 0x98, 0x75, 0xc2, 0x98, 0xbd, 0xd2, 0x15, 0xf7, 0x8f, 0xd8, 0xfa,
+	0xab, 0xfe, 0x9f, 0xcf, 0x48, 0xf6, 0x70, 0xe7, 0x5c, 0xd5, 0xb2, 0x2d, 0xf0, 0x47, 0x8f, 0x86,
+	0x18, 0xbb, 0x96, 0xf7, 0xf0, 0xc9, 0x8b, 0x1b, 0x89, 0xa2, 0x6b, 0x77, 0x9e, 0xc2, 0xd4, 0xf1,
+	0xb5, 0x97, 0x46, 0xc5, 0xd8, 0xb1, 0x25, 0xcc, 0x2b, 0xdc, 0xc9, 0x0d, 0xae, 0xad, 0x5d, 0x60,
+	0x87, 0xe0, 0x8e, 0x3e, 0x8a, 0x06, 0x93, 0xf7, 0x10, 0x5e, 0xa8, 0xef, 0xd8, 0xb2, 0x67, 0x10,
+	0x0c, 0xe0, 0x46, 0x42, 0xb7, 0xb1, 0xda, 0x29, 0x7b, 0x08, 0x21, 0x0d, 0xf2, 0x31, 0x80, 0x6b,
+	0xde, 0x3d, 0xfd, 0xb2, 0xac, 0x25, 0x7d, 0xed, 0xcb, 0x6c, 0xa3, 0x9a, 0xfc, 0x67, 0x45, 0x68,
+	0x28, 0x1f, 0x0d, 0xf2, 0xae, 0x7c, 0xd3, 0x95, 0xe5, 0xd4, 0xbe, 0xfe, 0xd5, 0x9f, 0x01, 0x00,
+	0x15, 0xe4, 0x44, 0x4b, 0x30, 0x03, 0x00, 0x00,
+}
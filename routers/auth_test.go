@@ -0,0 +1,188 @@
+package routers
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/middleware/policy"
+	"github.com/xdtest/project/models"
+)
+
+const testPassword = "Correct-Horse-42"
+
+// startServer 用内存里的 sqlite 启动完整的路由，返回服务和清理函数
+func startServer(t *testing.T) (*httptest.Server, *mailer.MemoryMailer, func()) {
+	gin.SetMode(gin.TestMode)
+	if err := policy.Default.Load("../config/policies.yaml"); err != nil {
+		t.Fatalf("load policies.yaml: %v", err)
+	}
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.DB().SetMaxOpenConns(1) //每个连接是一个单独的内存数据库
+	db.AutoMigrate(&models.User{}, &models.PasswordHistory{}, &models.Session{}, &models.SecurityEvent{},
+		&models.AuditLog{}, &models.Organization{}, &models.Membership{}, &models.EmailVerification{},
+		&models.UserEvent{}, &models.Webhook{}, &models.WebhookDelivery{})
+	prevDB := orm.Eloquent
+	models.UseDB(db)
+	if err := models.EnsureDefaultOrganization(); err != nil {
+		t.Fatalf("create default organization: %v", err)
+	}
+	m := mailer.NewMemoryMailer()
+	prevMailer := mailer.Default
+	mailer.Default = m
+	srv := httptest.NewServer(InitRouter())
+	return srv, m, func() {
+		srv.Close()
+		mailer.Default = prevMailer
+		orm.Eloquent = prevDB
+		db.Close()
+	}
+}
+
+func createUser(t *testing.T, name, email string, role int) models.User {
+	u := models.User{Name: name, Password: testPassword, Role: role, TenantId: models.DefaultTenantId, Status: models.UserActive}
+	u.SetEmail(email)
+	if _, err := u.Adduser(nil); err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return u
+}
+
+// login 用户名密码登录，返回token
+func login(t *testing.T, client *http.Client, srv *httptest.Server, name string) string {
+	t.Helper()
+	resp, err := client.PostForm(srv.URL+"/login", url.Values{"name": {name}, "password": {testPassword}})
+	if err != nil {
+		t.Fatalf("POST /login: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data struct{ Token string }
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusOK || body.Data.Token == "" {
+		t.Fatalf("POST /login status = %d, %v, want a token", resp.StatusCode, err)
+	}
+	return body.Data.Token
+}
+
+// do 带着token发请求，返回状态码和响应
+func do(t *testing.T, srv *httptest.Server, method, path, token string, body io.Reader) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+path, body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func countEvents(t *testing.T, userId int, typ string) int {
+	events, err := models.ListSecurityEvents(userId, 100, 0)
+	if err != nil {
+		t.Fatalf("list security events: %v", err)
+	}
+	n := 0
+	for _, e := range events {
+		if e.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+// 同一个浏览器第二次登录时带着第一次下发的cookie，不能当成新设备
+func TestLoginSameDeviceTwice(t *testing.T) {
+	srv, m, stop := startServer(t)
+	defer stop()
+	alice := createUser(t, "alice", "alice@example.com", models.RoleUser)
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+	login(t, browser, srv, "alice")
+	login(t, browser, srv, "alice")
+	if n := countEvents(t, alice.Id, models.EventNewDevice); n != 1 {
+		t.Errorf("got %d new device events after two logins from one browser, want 1", n)
+	}
+
+	// 不保存cookie的客户端按 User-Agent 认
+	login(t, &http.Client{}, srv, "alice")
+	login(t, &http.Client{}, srv, "alice")
+	if n := countEvents(t, alice.Id, models.EventNewDevice); n != 1 {
+		t.Errorf("got %d new device events after logins without cookies, want 1", n)
+	}
+	if len(m.Outbox()) != 1 {
+		t.Errorf("sent %d new device mails, want 1", len(m.Outbox()))
+	}
+}
+
+// 审计日志写不进去时不能模拟登录
+func TestImpersonateFailsWithoutAudit(t *testing.T) {
+	srv, _, stop := startServer(t)
+	defer stop()
+	createUser(t, "admin", "", models.RoleAdmin)
+	alice := createUser(t, "alice", "", models.RoleUser)
+	token := login(t, http.DefaultClient, srv, "admin")
+
+	if err := orm.Eloquent.DropTable(&models.AuditLog{}).Error; err != nil {
+		t.Fatalf("drop audit_logs: %v", err)
+	}
+	resp, body := do(t, srv, http.MethodPost, "/v1/admin/impersonate/"+strconv.Itoa(alice.Id), token, nil)
+	if resp.StatusCode != http.StatusInternalServerError || strings.Contains(body, "Token") {
+		t.Errorf("impersonate without audit log: status %d, body %s, want 500 without a token", resp.StatusCode, body)
+	}
+	if sessions, _ := models.ListSessions(alice.Id); len(sessions) != 0 {
+		t.Errorf("got %d sessions for alice, want none", len(sessions))
+	}
+}
+
+// 模拟登录时 revokeSession 也和别的 mutation 一样被拦下
+func TestGraphqlMutationBlockedWhileImpersonating(t *testing.T) {
+	srv, _, stop := startServer(t)
+	defer stop()
+	createUser(t, "admin", "", models.RoleAdmin)
+	alice := createUser(t, "alice", "", models.RoleUser)
+	login(t, http.DefaultClient, srv, "alice")
+	token := login(t, http.DefaultClient, srv, "admin")
+
+	resp, body := do(t, srv, http.MethodPost, "/v1/admin/impersonate/"+strconv.Itoa(alice.Id), token, nil)
+	var impersonated struct {
+		Data struct{ Token string }
+	}
+	if err := json.Unmarshal([]byte(body), &impersonated); err != nil || resp.StatusCode != http.StatusOK || impersonated.Data.Token == "" {
+		t.Fatalf("impersonate: status %d, body %s", resp.StatusCode, body)
+	}
+	sessions, _ := models.ListSessions(alice.Id)
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions for alice, want 2", len(sessions))
+	}
+
+	query := `{"query":"mutation { revokeSession(id: ` + strconv.Itoa(sessions[0].Id) + `) }"}`
+	resp, body = do(t, srv, http.MethodPost, "/graphql", impersonated.Data.Token, strings.NewReader(query))
+	if !strings.Contains(body, "模拟登录时不允许该操作") {
+		t.Errorf("revokeSession while impersonating: status %d, body %s, want it rejected", resp.StatusCode, body)
+	}
+	if after, _ := models.ListSessions(alice.Id); len(after) != 2 {
+		t.Errorf("got %d sessions for alice after revokeSession, want 2", len(after))
+	}
+}
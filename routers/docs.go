@@ -0,0 +1,122 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/xdtest/project/apis"
+	"github.com/xdtest/project/bulk"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/negotiate"
+	"github.com/xdtest/project/middleware/policy"
+	model "github.com/xdtest/project/models"
+	"github.com/xdtest/project/openapi"
+)
+
+// 常用的参数
+var (
+	tenantParam  = openapi.Param{Name: "tenant", Description: "组织的slug，不填是默认组织"}
+	accountParam = openapi.Param{Name: "account", Description: "用户名或邮箱", Required: true}
+	idQuery      = openapi.Param{Name: "id", Description: "用户id", Required: true}
+	tokenParam   = openapi.Param{Name: "token", Description: "邮件链接里的令牌", Required: true}
+	pageParams   = []openapi.Param{{Name: "limit", Description: "每页条数"}, {Name: "offset", Description: "跳过的条数"}}
+	// 经过 negotiate 中间件的接口还支持的格式
+	negotiated  = []string{negotiate.MsgPack, negotiate.YAML, negotiate.Protobuf}
+	ifNoneMatch = []openapi.Param{{Name: "If-None-Match", Description: "上次返回的ETag，没有变化时返回304"}}
+	ifMatch     = []openapi.Param{{Name: "If-Match", Description: "读取时返回的ETag，已被别人修改时返回412，没带返回428", Required: true}}
+	webhookForm = []openapi.Param{{Name: "url", Required: true}, {Name: "events", Description: "订阅的事件类型，逗号分隔，不填表示全部"},
+		{Name: "secret", Description: "签名用的密钥，不填时随机生成"}}
+)
+
+// Docs 接口说明，新增路由时在这里补上，routers 的测试会检查是否遗漏
+var Docs = openapi.Docs{
+	"GET /openapi.json": {Summary: "OpenAPI 3 接口文档", Tags: []string{"docs"}},
+	"GET /docs/*any":    {Summary: "Swagger UI", Tags: []string{"docs"}},
+
+	"GET /user_list_new_handler": {Summary: "当前组织的用户列表（已废弃，使用 GET /v1/users）", Tags: []string{"legacy"}, Deprecated: true, Auth: true, Formats: negotiated},
+	"POST /register": {Summary: "注册，Protobuf 的请求体是 UserInput，tenant 放在查询参数里", Tags: []string{"auth"},
+		Form: []openapi.Param{{Name: "name", Required: true}, {Name: "password", Required: true}, {Name: "email"}, tenantParam}, Formats: negotiated},
+	"POST /login": {Summary: "用户名密码登录", Tags: []string{"auth"}, Response: apis.LoginResult{},
+		Form: []openapi.Param{{Name: "name", Required: true}, {Name: "password", Required: true}, {Name: "device_name"}, tenantParam}, Formats: negotiated},
+	"POST /login/magic": {Summary: "申请免密登录链接，返回在其它设备打开链接时要输入的确认码", Tags: []string{"auth"},
+		Form: []openapi.Param{accountParam, tenantParam}},
+	"GET /login/magic": {Summary: "打开免密登录链接", Tags: []string{"auth"}, Response: apis.LoginResult{},
+		Query: []openapi.Param{tokenParam, {Name: "sig", Required: true}}},
+	"POST /login/magic/confirm": {Summary: "换了浏览器时确认免密登录", Tags: []string{"auth"}, Response: apis.LoginResult{},
+		Form: []openapi.Param{tokenParam, {Name: "sig", Required: true}, {Name: "code", Description: "申请链接时在原来的浏览器上显示的确认码", Required: true}}},
+	"DELETE /deleteuser": {Summary: "删除用户（已废弃，使用 DELETE /v1/users/{id}）", Tags: []string{"legacy"}, Deprecated: true, Auth: true,
+		Query: []openapi.Param{idQuery}, Formats: negotiated},
+	"POST /graphql": {Summary: "GraphQL 查询用户、会话和安全事件，限制深度和复杂度", Tags: []string{"graphql"}, Auth: true,
+		Body: apis.GraphqlRequest{}},
+	"POST /password/forgot": {Summary: "申请重置密码", Tags: []string{"password"},
+		Form: []openapi.Param{accountParam, tenantParam}},
+	"POST /password/reset": {Summary: "用邮件里的令牌重置密码", Tags: []string{"password"},
+		Form: []openapi.Param{tokenParam, {Name: "password", Required: true}}},
+	"GET /verify-email": {Summary: "验证邮箱", Tags: []string{"email"},
+		Query: []openapi.Param{tokenParam}},
+	"POST /invitations/accept": {Summary: "接受邀请完成注册", Tags: []string{"invitations"},
+		Form: []openapi.Param{tokenParam, {Name: "name", Required: true}, {Name: "password", Required: true}}},
+	"POST /verify-email/resend": {Summary: "重发验证邮件", Tags: []string{"email"},
+		Form: []openapi.Param{accountParam, tenantParam}},
+
+	"POST /v1/updatauser": {Summary: "修改用户（已废弃，使用 PATCH /v1/users/{id}）", Tags: []string{"legacy"}, Deprecated: true,
+		Query: []openapi.Param{idQuery}, Form: []openapi.Param{{Name: "name"}, {Name: "password"}}, Formats: negotiated},
+	"GET /v1/userinfo": {Summary: "查看用户（已废弃，使用 GET /v1/users/{id}）", Tags: []string{"legacy"}, Deprecated: true,
+		Query: []openapi.Param{idQuery}, Headers: ifNoneMatch, Formats: negotiated},
+	"POST /v1/users/:id/password-reset": {Summary: "帮用户发送重置密码邮件", Tags: []string{"password"}},
+	"GET /v1/orgs":                      {Summary: "加入的组织", Tags: []string{"orgs"}, Response: []model.Membership{}},
+	"POST /v1/orgs/:id/switch":          {Summary: "切换组织，返回新token", Tags: []string{"orgs"}, Response: apis.LoginResult{}},
+	"GET /v1/sessions":                  {Summary: "登录中的设备", Tags: []string{"sessions"}, Response: []apis.SessionView{}},
+	"DELETE /v1/sessions/:id":           {Summary: "退出某个设备", Tags: []string{"sessions"}},
+	"POST /v1/sessions/revoke-others":   {Summary: "退出其它所有设备", Tags: []string{"sessions"}, Response: int64(0)},
+	"GET /v1/me/security-events": {Summary: "自己的登录记录和安全事件", Tags: []string{"security"}, Response: []model.SecurityEvent{},
+		Query: pageParams},
+	"GET /v1/events/users": {Summary: "用 SSE 推送当前组织的用户事件，断线后带 Last-Event-ID 续传", Tags: []string{"events"},
+		Response: model.UserEventMessage{}, Headers: []openapi.Param{{Name: "Last-Event-ID", Description: "最后收到的事件id，从它之后继续推送"}},
+		Query: []openapi.Param{{Name: "types", Description: "只接收这些事件类型，逗号分隔：user.created,user.updated,user.deleted,user.role_changed"},
+			{Name: "user_id", Description: "只接收某个用户的事件"}, {Name: "last_event_id", Description: "同 Last-Event-ID，给不能设置请求头的客户端用"}}},
+	"POST /v1/test": {Summary: "检查token是否有效", Tags: []string{"auth"}, Response: jwt.CustomClaims{}},
+
+	"GET /v1/users":        {Summary: "当前组织的用户列表", Tags: []string{"users"}, Response: []apis.UserView{}, Formats: negotiated},
+	"POST /v1/users":       {Summary: "新建用户", Tags: []string{"users"}, Body: apis.UserInput{}, Response: apis.UserView{}, Status: http.StatusCreated, Formats: negotiated},
+	"GET /v1/users/:id":    {Summary: "查看用户", Tags: []string{"users"}, Response: apis.UserView{}, Headers: ifNoneMatch, Formats: negotiated},
+	"PUT /v1/users/:id":    {Summary: "整体修改用户资料，没带 email 表示清空", Tags: []string{"users"}, Body: apis.UserInput{}, Response: apis.UserView{}, Headers: ifMatch, Formats: negotiated},
+	"PATCH /v1/users/:id":  {Summary: "按 JSON merge patch 修改用户资料", Tags: []string{"users"}, Body: apis.UserInput{}, BodyType: "application/merge-patch+json", Response: apis.UserView{}, Headers: ifMatch, Formats: negotiated},
+	"DELETE /v1/users/:id": {Summary: "删除用户", Tags: []string{"users"}, Status: http.StatusNoContent, Headers: ifMatch, Formats: negotiated},
+	"GET /v1/me":           {Summary: "当前登录的用户", Tags: []string{"users"}, Response: apis.UserView{}, Headers: ifNoneMatch, Formats: negotiated},
+	"PATCH /v1/me":         {Summary: "修改自己的资料", Tags: []string{"users"}, Body: apis.UserInput{}, BodyType: "application/merge-patch+json", Response: apis.UserView{}, Headers: ifMatch, Formats: negotiated},
+
+	"GET /v1/admin/users/:id/security-events": {Summary: "某个用户的安全事件", Tags: []string{"admin"}, Response: []model.SecurityEvent{},
+		Query: pageParams},
+	"POST /v1/admin/import/users": {Summary: "从CSV、JSON Lines批量导入用户，也可以用 multipart 表单的 file 字段上传", Tags: []string{"admin"},
+		Body: "", BodyType: "text/csv", Response: bulk.Report{},
+		Query: []openapi.Param{{Name: "format", Description: "csv 或 jsonl，不写时按文件名、Content-Type 判断"},
+			{Name: "mode", Description: "用户名已存在时 skip 或 upsert，默认 skip"}, {Name: "dry_run", Description: "true 时只校验不写入"},
+			{Name: "batch_size", Description: "每个事务的行数，默认100"}}},
+	"GET /v1/admin/export/users": {Summary: "导出用户，返回CSV或JSON Lines文件", Tags: []string{"admin"},
+		Query: []openapi.Param{{Name: "format", Description: "csv 或 jsonl，默认 csv"}}},
+	"GET /v1/admin/audit-logs": {Summary: "查询审计日志", Tags: []string{"admin"}, Response: []model.AuditLog{},
+		Query: append([]openapi.Param{{Name: "actor"}, {Name: "target"}, {Name: "action"},
+			{Name: "since", Description: "RFC3339"}, {Name: "until", Description: "RFC3339"}}, pageParams...)},
+	"GET /v1/admin/audit-logs/verify": {Summary: "校验审计日志的hash链", Tags: []string{"admin"}},
+	"POST /v1/admin/impersonate/:id":  {Summary: "模拟登录某个用户", Tags: []string{"admin"}, Response: apis.LoginResult{}},
+	"POST /v1/admin/orgs": {Summary: "新建组织", Tags: []string{"admin"}, Response: model.Organization{},
+		Form: []openapi.Param{{Name: "name", Required: true}, {Name: "slug", Required: true}}},
+	"POST /v1/admin/orgs/:id/members": {Summary: "修改成员的角色，或者把收到邀请的用户加入组织", Tags: []string{"admin"}, Response: model.Membership{},
+		Form: []openapi.Param{{Name: "user_id", Required: true}, {Name: "role"}}},
+	"POST /v1/admin/invitations": {Summary: "邀请用户", Tags: []string{"admin"}, Response: model.Invitation{},
+		Form: []openapi.Param{{Name: "email", Required: true}, {Name: "role"}}},
+	"GET /v1/admin/invitations":                {Summary: "发出的邀请", Tags: []string{"admin"}, Response: []model.Invitation{}},
+	"GET /v1/admin/registrations":              {Summary: "等待审核的注册", Tags: []string{"admin"}, Response: []model.User{}},
+	"POST /v1/admin/registrations/:id/approve": {Summary: "通过注册申请", Tags: []string{"admin"}},
+	"POST /v1/admin/registrations/:id/reject":  {Summary: "拒绝注册申请", Tags: []string{"admin"}},
+	"POST /v1/admin/policy/explain":            {Summary: "调试策略", Tags: []string{"admin"}, Body: policy.Request{}, Response: policy.Decision{}},
+	"POST /v1/admin/webhooks": {Summary: "添加 webhook 订阅，返回的 secret 用来校验 X-Webhook-Signature", Tags: []string{"webhooks"}, Response: model.Webhook{},
+		Form: webhookForm},
+	"GET /v1/admin/webhooks": {Summary: "webhook 订阅列表", Tags: []string{"webhooks"}, Response: []model.Webhook{}},
+	"PUT /v1/admin/webhooks/:id": {Summary: "修改 webhook 订阅，没带的参数不修改", Tags: []string{"webhooks"}, Response: model.Webhook{},
+		Form: append(webhookForm, openapi.Param{Name: "active", Description: "true 或 false"})},
+	"DELETE /v1/admin/webhooks/:id": {Summary: "删除 webhook 订阅和投递记录", Tags: []string{"webhooks"}},
+	"GET /v1/admin/webhooks/:id/deliveries": {Summary: "webhook 的投递记录，最新的在前", Tags: []string{"webhooks"}, Response: []model.WebhookDelivery{},
+		Query: pageParams},
+	"POST /v1/admin/webhooks/:id/deliveries/:delivery/redeliver": {Summary: "用原来的内容重新发送一次投递", Tags: []string{"webhooks"}, Response: model.WebhookDelivery{}},
+}
@@ -0,0 +1,64 @@
+package routers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xdtest/project/middleware/negotiate"
+	"github.com/xdtest/project/models"
+	"github.com/xdtest/project/pb"
+)
+
+// 旧接口也按 Content-Type、Accept 协商格式
+func TestLegacyUserEndpointsNegotiate(t *testing.T) {
+	srv, _, stop := startServer(t)
+	defer stop()
+	alice := createUser(t, "alice", "", models.RoleUser)
+	token := login(t, http.DefaultClient, srv, "alice")
+	id := strconv.Itoa(alice.Id)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/updatauser?id="+id, strings.NewReader("name: alice2\n"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", negotiate.YAML)
+	req.Header.Set("Accept", negotiate.Protobuf)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /v1/updatauser: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var user pb.User
+	if resp.StatusCode != http.StatusOK || proto.Unmarshal(data, &user) != nil || user.Name != "alice2" {
+		t.Fatalf("POST /v1/updatauser: status %d, %q, want a protobuf user named alice2", resp.StatusCode, data)
+	}
+
+	// 请求体太大时是413
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/updatauser?id="+id, strings.NewReader("name: "+strings.Repeat("x", int(negotiate.MaxBodySize))))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", negotiate.YAML)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /v1/updatauser: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /v1/updatauser with a large body: status %d, want 413", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/deleteuser?id="+id, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", negotiate.YAML)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /deleteuser: %v", err)
+	}
+	data, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), negotiate.YAML) || !strings.Contains(string(data), "name: alice2") {
+		t.Errorf("DELETE /deleteuser: status %d, %s, %q, want YAML", resp.StatusCode, resp.Header.Get("Content-Type"), data)
+	}
+}
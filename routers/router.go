@@ -6,21 +6,104 @@ import (
 
 	"github.com/gin-gonic/gin"
 	. "github.com/xdtest/project/apis"
+	"github.com/xdtest/project/middleware/deprecation"
+	"github.com/xdtest/project/middleware/errhandler"
 	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/middleware/negotiate"
+	"github.com/xdtest/project/middleware/policy"
+	"github.com/xdtest/project/middleware/requestid"
+	model "github.com/xdtest/project/models"
+	"github.com/xdtest/project/openapi"
 )
 
 func InitRouter() *gin.Engine {
 	f, _ := os.Create("logs/productions.log")
 	gin.DefaultWriter = io.MultiWriter(f)
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Logger())
+	router.Use(requestid.RequestID()) //每个请求一个ID，写进审计日志
+	router.Use(errhandler.Handler())  //统一处理 c.Error 和 panic，出错不会让进程退出
 	v1 := router.Group("/v1")
-	v1.Use(jwt.JWTAuth())                              //v1 使用jwt中间件进行前后验证
-	router.GET("/user_list_new_handler", Getuserslist) //注意这里调用handler方法直接调用函数名
-	router.POST("/register", Addnewuser)               //注意这里调用handler方法直接调用函数名
-	router.POST("/login", Userlogin)                   //注意这里调用handler方法直接调用函数名
-	router.DELETE("/deleteuser", Deleteuser)           //注意这里调用handler方法直接调用函数名
-	v1.POST("/updatauser", Updatauser)                 //注意这里调用handler方法直接调用函数名
-	v1.POST("/test", GetDataByTime)                    //使用中间件，验证token， 函数也是验证用户带的token
+	v1.Use(jwt.JWTAuth()) //v1 使用jwt中间件进行前后验证
+	// limited策略下未验证邮箱不能修改资料
+	verified := jwt.RequireVerifiedEmail(model.EmailPolicy == model.EmailPolicyLimited)
+	// 只能读、改、删自己的账号，管理员有对应权限可以操作任何人
+	canRead := policy.RequireOwnerOr(policy.PermUserReadAny, policy.QueryID("id"))
+	canUpdate := policy.RequireOwnerOr(policy.PermUserUpdateAny, policy.QueryID("id"))
+	canDelete := policy.RequireOwnerOr(policy.PermUserDeleteAny, policy.QueryID("id"))
+	// RESTful 的 /v1/users 从路径取id
+	canList := policy.RequirePermission(policy.PermUserReadAny)
+	canCreate := policy.RequirePermission(policy.PermUserCreateAny)
+	canReadUser := policy.RequireOwnerOr(policy.PermUserReadAny, policy.ParamID("id"))
+	canUpdateUser := policy.RequireOwnerOr(policy.PermUserUpdateAny, policy.ParamID("id"))
+	canDeleteUser := policy.RequireOwnerOr(policy.PermUserDeleteAny, policy.ParamID("id"))
+	// 旧接口保留，响应里带上 Deprecation/Sunset 头并记录调用
+	legacyList := deprecation.Deprecated("/v1/users")
+	legacyUser := deprecation.Deprecated("/v1/users/{id}")
+	// 更细的规则写在策略文件里
+	canSendReset := policy.Authorize("user:reset_password", policy.UserResource(policy.ParamID("id")))
+	// 按 Accept、Content-Type 使用 JSON、MessagePack、YAML 或 Protobuf
+	negotiated := negotiate.Negotiate()
+
+	router.GET("/user_list_new_handler", legacyList, jwt.JWTAuth(), negotiated, canList, Getuserslist) //注意这里调用handler方法直接调用函数名
+	router.POST("/register", negotiated, Addnewuser)                                                   //注意这里调用handler方法直接调用函数名
+	router.POST("/login", negotiated, Userlogin)                                                       //注意这里调用handler方法直接调用函数名
+	router.POST("/login/magic", Requestmagiclink)                                                      //申请免密登录链接
+	router.GET("/login/magic", Magiclogin)                                                             //邮件里的免密登录链接
+	router.POST("/login/magic/confirm", Confirmmagiclogin)                                             //换了浏览器时确认登录
+	router.DELETE("/deleteuser", legacyUser, jwt.JWTAuth(), negotiated, canDelete, Deleteuser)         //注意这里调用handler方法直接调用函数名
+	router.POST("/graphql", jwt.JWTAuth(), Graphql)                                                    //用户、会话、安全事件的 GraphQL 查询
+	router.POST("/password/forgot", Forgotpassword)                                                    //申请重置密码，发邮件
+	router.POST("/password/reset", Resetpassword)                                                      //用邮件里的令牌重置密码
+	router.GET("/verify-email", Verifyemail)                                                           //邮件里的验证链接
+	router.POST("/invitations/accept", Acceptinvitation)                                               //接受邀请完成注册
+	router.POST("/verify-email/resend", Resendverification)                                            //重发验证邮件
+	v1.POST("/updatauser", legacyUser, negotiated, verified, canUpdate, Updatauser)                    //注意这里调用handler方法直接调用函数名
+	v1.GET("/userinfo", legacyUser, negotiated, canRead, Getuserinfo)                                  //查看用户资料
+	v1.POST("/users/:id/password-reset", canSendReset, Sendpasswordreset)                              //帮用户发重置密码邮件，由策略文件控制
+	v1.GET("/orgs", Myorganizations)                                                                   //加入的组织
+	v1.POST("/orgs/:id/switch", Switchorganization)                                                    //切换组织，返回新token
+	v1.GET("/sessions", Listsessions)                                                                  //当前用户登录中的设备
+	v1.DELETE("/sessions/:id", Revokesession)                                                          //退出某个设备
+	v1.POST("/sessions/revoke-others", Revokeothersessions)                                            //退出其它所有设备
+	v1.GET("/me/security-events", Mysecurityevents)                                                    //自己的登录记录和安全事件
+	v1.GET("/events/users", jwt.RequireAdmin(), Streamuserevents)                                      //用 SSE 推送用户的新建、修改、删除、角色变化
+	v1.POST("/test", GetDataByTime)                                                                    //使用中间件，验证token， 函数也是验证用户带的token
+
+	v1.GET("/users", negotiated, canList, Indexusers)                      //当前组织的用户列表
+	v1.POST("/users", negotiated, canCreate, Storeuser)                    //新建用户
+	v1.GET("/users/:id", negotiated, canReadUser, Showuser)                //查看用户
+	v1.PUT("/users/:id", negotiated, verified, canUpdateUser, Replaceuser) //整体修改用户资料
+	v1.PATCH("/users/:id", negotiated, verified, canUpdateUser, Patchuser) //按 JSON merge patch 修改用户资料
+	v1.DELETE("/users/:id", negotiated, canDeleteUser, Destroyuser)        //删除用户
+	v1.GET("/me", negotiated, Showme)                                      //当前登录的用户
+	v1.PATCH("/me", negotiated, verified, Patchme)                         //修改自己的资料
+
+	admin := v1.Group("/admin", jwt.RequireAdmin())               //只有管理员能访问
+	admin.GET("/users/:id/security-events", Usersecurityevents)   //某个用户的安全事件
+	admin.POST("/import/users", Importusers)                      //从CSV、JSON Lines批量导入用户
+	admin.GET("/export/users", Exportusers)                       //导出用户为CSV、JSON Lines
+	admin.GET("/audit-logs", Auditlogs)                           //查询审计日志
+	admin.GET("/audit-logs/verify", Verifyauditlogs)              //校验审计日志的hash链
+	admin.POST("/impersonate/:id", Impersonate)                   //模拟登录某个用户
+	admin.POST("/orgs", Createorganization)                       //新建组织
+	admin.POST("/orgs/:id/members", Addmember)                    //修改成员角色或加入受邀用户
+	admin.POST("/invitations", Createinvitation)                  //邀请用户
+	admin.GET("/invitations", Listinvitations)                    //发出的邀请
+	admin.GET("/registrations", Pendingregistrations)             //等待审核的注册
+	admin.POST("/registrations/:id/approve", Approveregistration) //通过注册申请
+	admin.POST("/registrations/:id/reject", Rejectregistration)   //拒绝注册申请
+	admin.POST("/policy/explain", policy.Explain)                 //调试策略，返回每条规则的匹配过程
+
+	admin.POST("/webhooks", Createwebhook)                                       //添加 webhook 订阅
+	admin.GET("/webhooks", Listwebhooks)                                         //webhook 订阅列表
+	admin.PUT("/webhooks/:id", Updatewebhook)                                    //修改 webhook 订阅
+	admin.DELETE("/webhooks/:id", Deletewebhook)                                 //删除 webhook 订阅
+	admin.GET("/webhooks/:id/deliveries", Webhookdeliveries)                     //webhook 的投递记录
+	admin.POST("/webhooks/:id/deliveries/:delivery/redeliver", Redeliverwebhook) //重新发送一次投递
+
+	router.GET("/openapi.json", openapi.Handler(router, Docs)) //按注册的路由和 Docs 生成的接口文档
+	router.GET("/docs/*any", openapi.UI("/openapi.json"))      //离线的 Swagger UI
 	return router
 }
@@ -0,0 +1,74 @@
+package routers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xdtest/project/middleware/jwt"
+	"github.com/xdtest/project/openapi"
+)
+
+// 每个注册的路由都要出现在 /openapi.json 里，新增路由忘了写 Docs 时这里会失败
+func TestOpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := InitRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d", w.Code)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode openapi.json: %v", err)
+	}
+	if doc.OpenAPI == "" || len(doc.Paths) == 0 {
+		t.Fatalf("empty document: %s", w.Body.String())
+	}
+	for _, r := range router.Routes() {
+		if !doc.Has(r.Method, r.Path) {
+			t.Errorf("%s %s is registered but missing from the OpenAPI document, add it to routers.Docs", r.Method, r.Path)
+		}
+	}
+	for key := range Docs {
+		found := false
+		for _, r := range router.Routes() {
+			if r.Method+" "+r.Path == key {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Docs has %q but no such route is registered", key)
+		}
+	}
+}
+
+func TestDocsUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := InitRouter()
+	for _, path := range []string{"/docs/", "/docs/swagger-ui-bundle.js"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("GET %s status = %d, %d bytes", path, w.Code, w.Body.Len())
+		}
+	}
+}
+
+// 模拟登录白名单里的接口必须存在，改了路由忘了改白名单时这里会失败
+func TestImpersonationAllowlistRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := InitRouter()
+	registered := map[string]bool{}
+	for _, r := range router.Routes() {
+		registered[r.Method+" "+r.Path] = true
+	}
+	for route := range jwt.ImpersonationAllowlist {
+		if !registered[route] {
+			t.Errorf("jwt.ImpersonationAllowlist has %q but no such route is registered", route)
+		}
+	}
+}
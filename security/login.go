@@ -0,0 +1,66 @@
+// Package security HTTP 和 gRPC 登录共用的防护：统一失败耗时、记录安全事件、新设备提醒
+package security
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xdtest/project/mailer"
+	"github.com/xdtest/project/models"
+)
+
+// AuthFailureMinDuration 认证失败的响应至少耗时这么久，抹平不同失败原因的耗时差异
+var AuthFailureMinDuration = 300 * time.Millisecond
+
+// PadTiming 从start开始补足到 AuthFailureMinDuration
+func PadTiming(start time.Time) {
+	if d := AuthFailureMinDuration - time.Since(start); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Client 发起登录的客户端
+type Client struct {
+	IP        string
+	UserAgent string
+	Device    string // 设备标识的hash，HTTP 有cookie时是cookie，否则是 User-Agent
+}
+
+// RecordEvent 记录一条安全事件，失败只打日志不影响请求
+func RecordEvent(c Client, userId int, account, typ string) {
+	event := models.SecurityEvent{
+		UserId:     userId,
+		Account:    account,
+		Type:       typ,
+		IP:         c.IP,
+		UserAgent:  c.UserAgent,
+		DeviceHash: c.Device,
+	}
+	if err := models.RecordSecurityEvent(&event); err != nil {
+		log.Println("record security event error", err)
+	}
+}
+
+// RecordLoginSuccess 记录登录成功，从没见过的设备额外记一条并发邮件提醒
+// issued 是这次登录新下发的设备标识的hash，不为空时登录成功按它记，下次带着它登录能认出是同一个设备
+func RecordLoginSuccess(c Client, user models.User, issued string) {
+	known, err := models.IsKnownDevice(user.Id, c.Device)
+	if err != nil {
+		log.Println("check known device error", err)
+	}
+	if err == nil && !known {
+		RecordEvent(c, user.Id, user.Name, models.EventNewDevice)
+		if user.GetEmail() != "" {
+			body := fmt.Sprintf("%s 你好，你的账号于 %s 在新设备上登录。\nIP：%s\n设备：%s\n如果不是你本人操作，请立即修改密码。",
+				user.Name, time.Now().Format("2006-01-02 15:04:05"), c.IP, c.UserAgent)
+			if err := mailer.Send(user.GetEmail(), "新设备登录提醒", body); err != nil {
+				log.Println("send new device mail error", err)
+			}
+		}
+	}
+	if issued != "" {
+		c.Device = issued
+	}
+	RecordEvent(c, user.Id, user.Name, models.EventLoginSuccess)
+}
@@ -0,0 +1,174 @@
+// Package stream 把 user_events 表里的新事件推给订阅的连接
+// 所有连接共用一个轮询，其它进程（如 users-import 命令）写入的事件也能推送出去
+package stream
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xdtest/project/models"
+)
+
+// 推送相关的配置
+var (
+	PollInterval   = time.Second // 多久查一次新事件，本进程写入的事件会立即唤醒
+	BufferSize     = 64          // 每个连接缓冲的事件数，满了以后这个连接改为从事件表补读
+	MaxSubscribers = 100         // 同时订阅的连接数上限
+)
+
+var ErrTooManySubscribers = errors.New("too many event subscribers")
+
+// Filter 连接只接收符合条件的事件，零值表示不限制
+type Filter struct {
+	TenantId int
+	UserId   int
+	Types    []string
+}
+
+// Match 事件是否符合条件
+func (f Filter) Match(e models.UserEvent) bool {
+	if f.TenantId != 0 && e.TenantId != f.TenantId {
+		return false
+	}
+	if f.UserId != 0 && e.UserId != f.UserId {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Query 从事件表补读时的查询条件
+func (f Filter) Query(afterId, limit int) models.UserEventFilter {
+	return models.UserEventFilter{TenantId: f.TenantId, UserId: f.UserId, Types: f.Types, AfterId: afterId, Limit: limit}
+}
+
+// Subscription 一个连接的订阅，事件按id从小到大送到 C
+type Subscription struct {
+	C      chan models.UserEvent
+	filter Filter
+	lagged int32
+}
+
+// Lagged 缓冲满过、有事件没送进 C 时返回 true，并清除标记
+// 调用方应该从事件表补读，C 里已经处理过的事件按id跳过
+func (s *Subscription) Lagged() bool {
+	return atomic.SwapInt32(&s.lagged, 0) == 1
+}
+
+// Broker 轮询 user_events 表，把新事件分发给订阅者；慢的连接不会阻塞分发
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]bool
+	lastId int
+	wake   chan struct{}
+	once   sync.Once
+}
+
+// Default 默认的 Broker，models 写入事件后会唤醒它
+var Default = &Broker{}
+
+func init() {
+	models.NotifyUserEvent = Default.Notify
+}
+
+// Subscribe 订阅之后发生的事件，用完需要 Unsubscribe
+func (b *Broker) Subscribe(f Filter) (*Subscription, error) {
+	b.once.Do(b.start)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if MaxSubscribers > 0 && len(b.subs) >= MaxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	s := &Subscription{C: make(chan models.UserEvent, BufferSize), filter: f}
+	b.subs[s] = true
+	return s, nil
+}
+
+// Unsubscribe 取消订阅
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// Notify 有新事件写入，不等 PollInterval 立即查一次
+func (b *Broker) Notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Broker) start() {
+	b.subs = map[*Subscription]bool{}
+	b.wake = make(chan struct{}, 1)
+	_, b.lastId, _ = models.UserEventRange()
+	go b.run()
+}
+
+func (b *Broker) run() {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.wake:
+		}
+		if err := b.poll(); err != nil {
+			log.Println("poll user events error", err)
+		}
+	}
+}
+
+// poll 读出 lastId 之后的事件并分发，一次读不完就接着读
+func (b *Broker) poll() error {
+	for {
+		b.mu.Lock()
+		idle := len(b.subs) == 0
+		b.mu.Unlock()
+		if idle { //没有订阅者时只记录读到哪里
+			_, latest, err := models.UserEventRange()
+			if err == nil {
+				b.lastId = latest
+			}
+			return err
+		}
+		events, err := models.ListUserEvents(models.UserEventFilter{AfterId: b.lastId, Limit: 500})
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			b.dispatch(e)
+			b.lastId = e.Id
+		}
+		if len(events) < 500 {
+			return nil
+		}
+	}
+}
+
+// dispatch 缓冲满的连接不等待，标记为 lagged，由连接自己补读
+func (b *Broker) dispatch(e models.UserEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+			atomic.StoreInt32(&s.lagged, 1)
+		}
+	}
+}
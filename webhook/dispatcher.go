@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/xdtest/project/errs"
+	"github.com/xdtest/project/models"
+)
+
+// 重试相关的配置
+var (
+	MaxAttempts = 8                // 最多发送几次，之后标记为 dead
+	RetryBase   = 30 * time.Second // 第一次失败后等多久重试，之后每次翻倍
+	RetryMax    = 6 * time.Hour    // 重试间隔的上限
+)
+
+// 记录对方返回内容的最大长度
+const maxResponseLog = 1024
+
+// RetryDelay 第 attempts 次发送失败后等多久再发
+func RetryDelay(attempts int) time.Duration {
+	d := RetryBase
+	for i := 1; i < attempts && d < RetryMax; i++ {
+		d *= 2
+	}
+	if d > RetryMax {
+		d = RetryMax
+	}
+	return d
+}
+
+// Dispatcher 轮询 webhook_deliveries 表，把到期的投递发出去
+// 多个进程同时运行时用 ClaimDelivery 保证一次投递只有一个进程在发
+type Dispatcher struct {
+	Client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration // 领取后多久没有结果（进程崩溃）可以被重新领取，要大于 Client 的超时
+}
+
+// ErrPrivateAddress URL 解析出来是本机或内网地址
+var ErrPrivateAddress = errors.New("webhook 不能发往本机或内网地址")
+
+// 不允许连接的网段，IsLoopback 等判断不了的私有地址
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"} {
+		_, n, _ := net.ParseCIDR(s)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// PrivateIP 是否是本机、内网、链路本地或未指定的地址，webhook 不能发到这些地址
+func PrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialControl 在域名解析之后、建立连接之前检查对方地址，DNS 重新绑定到内网也能挡住
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || PrivateIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Default 默认的 Dispatcher，main 里启动
+// 不走环境变量里的代理，否则连接的是代理，检查不到真正的地址
+var Default = &Dispatcher{
+	Client: &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: dialControl}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse //不跟随跳转，3xx 按失败处理
+		},
+	},
+	PollInterval: 5 * time.Second,
+	BatchSize:    50,
+	Lease:        time.Minute,
+}
+
+// Run 一直轮询，不返回
+func (d *Dispatcher) Run() {
+	for {
+		if _, err := d.ProcessDue(); err != nil {
+			log.Println("webhook dispatch error", err)
+		}
+		time.Sleep(d.PollInterval)
+	}
+}
+
+// ProcessDue 发送所有到期的投递，返回发送的次数
+func (d *Dispatcher) ProcessDue() (int, error) {
+	sent := 0
+	for {
+		list, err := models.DueDeliveries(d.BatchSize)
+		if err != nil {
+			return sent, err
+		}
+		for i := range list {
+			ok, err := models.ClaimDelivery(&list[i], d.Lease)
+			if err != nil {
+				return sent, err
+			}
+			if !ok { //别的进程已经领走了
+				continue
+			}
+			if err := d.deliver(&list[i]); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+		if len(list) < d.BatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// deliver 发送一次并保存结果
+func (d *Dispatcher) deliver(delivery *models.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	hook, err := models.FindWebhook(delivery.WebhookId)
+	if errors.Is(err, errs.ErrNotFound) || err == nil && !hook.Active {
+		delivery.Status = models.DeliveryDead
+		delivery.LastStatusCode = 0
+		delivery.LastError = "webhook 已删除或已停用"
+		delivery.LastResponse = ""
+		return models.SaveDeliveryAttempt(delivery)
+	}
+	if err != nil {
+		return err
+	}
+
+	code, body, err := d.post(hook, delivery)
+	delivery.LastDuration = int(time.Since(now) / time.Millisecond)
+	delivery.LastStatusCode = code
+	delivery.LastResponse = body
+	delivery.LastError = ""
+	switch {
+	case err != nil:
+		delivery.LastError = truncate(err.Error(), 512)
+	case code < 200 || code > 299:
+		delivery.LastError = "HTTP " + strconv.Itoa(code)
+	}
+	if delivery.LastError == "" {
+		delivery.Status = models.DeliverySucceeded
+	} else if delivery.Attempts >= MaxAttempts {
+		delivery.Status = models.DeliveryDead
+	} else {
+		delivery.NextAttemptAt = now.Add(RetryDelay(delivery.Attempts))
+	}
+	return models.SaveDeliveryAttempt(delivery)
+}
+
+func (d *Dispatcher) post(hook models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "project-webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.Id))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, ts, body))
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseLog))
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10)) //读完以便复用连接
+	return resp.StatusCode, string(data), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
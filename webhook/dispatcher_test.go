@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	orm "github.com/xdtest/project/database"
+	"github.com/xdtest/project/models"
+)
+
+const testSecret = "webhook-secret"
+
+// setup 用内存里的 sqlite，返回清理函数
+func setup(t *testing.T) func() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.DB().SetMaxOpenConns(1) //每个连接是一个单独的内存数据库
+	db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{})
+	prevDB := orm.Eloquent
+	models.UseDB(db)
+	return func() {
+		orm.Eloquent = prevDB
+		db.Close()
+	}
+}
+
+// testDispatcher 测试的接收方在本机，不用 Default 里检查地址的 Client
+func testDispatcher() *Dispatcher {
+	d := *Default
+	d.Client = &http.Client{Timeout: 5 * time.Second, CheckRedirect: Default.Client.CheckRedirect}
+	d.BatchSize = 10
+	return &d
+}
+
+// enqueue 给 url 建一个订阅和一条马上要发的投递
+func enqueue(t *testing.T, url string) models.WebhookDelivery {
+	hook := models.Webhook{TenantId: 1, URL: url, Secret: testSecret, Active: true}
+	if err := hook.CreateWebhook(); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	d := models.WebhookDelivery{WebhookId: hook.Id, EventId: 1, EventType: models.UserCreated,
+		Payload: `{"id":1,"type":"user.created"}`, Status: models.DeliveryPending, NextAttemptAt: time.Now()}
+	if err := orm.Eloquent.Create(&d).Error; err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+	return d
+}
+
+func reload(t *testing.T, id int) models.WebhookDelivery {
+	var d models.WebhookDelivery
+	if err := orm.Eloquent.First(&d, id).Error; err != nil {
+		t.Fatalf("load delivery %d: %v", id, err)
+	}
+	return d
+}
+
+// makeDue 把下次发送时间改到现在，不用等重试间隔
+func makeDue(t *testing.T, id int) {
+	if err := orm.Eloquent.Model(&models.WebhookDelivery{}).Where("id = ?", id).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("update delivery: %v", err)
+	}
+}
+
+func process(t *testing.T, d *Dispatcher, want int) {
+	t.Helper()
+	n, err := d.ProcessDue()
+	if err != nil || n != want {
+		t.Fatalf("ProcessDue = %d, %v, want %d", n, err, want)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	defer func(base, max time.Duration) { RetryBase, RetryMax = base, max }(RetryBase, RetryMax)
+	RetryBase, RetryMax = 30*time.Second, 10*time.Minute
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute}, // 封顶
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := RetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	defer setup(t)()
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	d := enqueue(t, srv.URL)
+
+	process(t, testDispatcher(), 1)
+	if got == nil {
+		t.Fatal("receiver got no request")
+	}
+	if !Verify(testSecret, got.Header.Get(HeaderTimestamp), got.Header.Get(HeaderSignature), body, time.Now()) {
+		t.Errorf("signature %q does not verify", got.Header.Get(HeaderSignature))
+	}
+	if got.Header.Get(HeaderEvent) != models.UserCreated || got.Header.Get(HeaderDelivery) != strconv.Itoa(d.Id) {
+		t.Errorf("headers %v, want event %s and delivery %d", got.Header, models.UserCreated, d.Id)
+	}
+	if string(body) != d.Payload {
+		t.Errorf("body = %s, want %s", body, d.Payload)
+	}
+	if d = reload(t, d.Id); d.Status != models.DeliverySucceeded || d.Attempts != 1 || d.LastStatusCode != 200 {
+		t.Errorf("delivery = %+v, want succeeded after 1 attempt", d)
+	}
+	process(t, testDispatcher(), 0) //成功后不再发
+}
+
+func TestRetryUntilDead(t *testing.T) {
+	defer setup(t)()
+	defer func(n int) { MaxAttempts = n }(MaxAttempts)
+	MaxAttempts = 3
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	d := enqueue(t, srv.URL)
+	disp := testDispatcher()
+
+	start := time.Now()
+	process(t, disp, 1)
+	got := reload(t, d.Id)
+	if got.Status != models.DeliveryPending || got.LastError != "HTTP 500" {
+		t.Fatalf("after 1 failure: %+v, want pending with HTTP 500", got)
+	}
+	// 下次发送时间按 RetryDelay 推迟，没到时间不发
+	if wait := got.NextAttemptAt.Sub(start); wait < RetryDelay(1)-time.Second || wait > RetryDelay(1)+time.Second {
+		t.Errorf("next attempt in %v, want about %v", wait, RetryDelay(1))
+	}
+	process(t, disp, 0)
+
+	for i := 2; i <= MaxAttempts; i++ {
+		makeDue(t, d.Id)
+		process(t, disp, 1)
+	}
+	if got = reload(t, d.Id); got.Status != models.DeliveryDead || got.Attempts != MaxAttempts {
+		t.Fatalf("after %d failures: %+v, want dead", MaxAttempts, got)
+	}
+	makeDue(t, d.Id)
+	process(t, disp, 0) //dead 的不再发
+	if hits != MaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", hits, MaxAttempts)
+	}
+}
+
+// 跳转可能指向内网，不跟随，3xx 按失败处理
+func TestNoRedirect(t *testing.T) {
+	defer setup(t)()
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+	d := enqueue(t, srv.URL)
+
+	process(t, testDispatcher(), 1)
+	if followed {
+		t.Error("dispatcher followed the redirect")
+	}
+	if d = reload(t, d.Id); d.Status != models.DeliveryPending || d.LastStatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("delivery = %+v, want pending with status 307", d)
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	defer setup(t)()
+	defer func(n int) { MaxAttempts = n }(MaxAttempts)
+	MaxAttempts = 1
+	fail := true
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(HeaderDelivery))
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	d := enqueue(t, srv.URL)
+	disp := testDispatcher()
+
+	process(t, disp, 1)
+	if got := reload(t, d.Id); got.Status != models.DeliveryDead {
+		t.Fatalf("delivery = %+v, want dead", got)
+	}
+	fail = false
+	re, err := models.Redeliver(d.WebhookId, d.Id)
+	if err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	process(t, disp, 1)
+	got := reload(t, re.Id)
+	if got.Status != models.DeliverySucceeded || got.RedeliveryOf != d.Id || got.Payload != d.Payload {
+		t.Errorf("redelivery = %+v, want succeeded with the original payload", got)
+	}
+	if orig := reload(t, d.Id); orig.Status != models.DeliveryDead { //原来的记录保留
+		t.Errorf("original delivery = %+v, want still dead", orig)
+	}
+	if len(ids) != 2 || ids[1] != strconv.Itoa(re.Id) {
+		t.Errorf("delivery ids sent = %v, want %d then %d", ids, d.Id, re.Id)
+	}
+}
+
+// Default 的 Client 在连接时检查地址，不能发往本机
+func TestDefaultClientRejectsPrivateAddress(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer srv.Close()
+	_, err := Default.Client.Post(srv.URL, "application/json", nil)
+	if !errors.Is(err, ErrPrivateAddress) || hit {
+		t.Errorf("POST %s = %v, want %v", srv.URL, err, ErrPrivateAddress)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, //云服务器的元数据地址
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"::ffff:127.0.0.1", true},
+		{"100.64.0.1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := PrivateIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("PrivateIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
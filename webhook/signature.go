@@ -0,0 +1,46 @@
+// Package webhook 把用户事件 POST 给组织订阅的 URL，签名、失败重试
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 发送时带的请求头
+const (
+	HeaderEvent     = "X-Webhook-Event"     //事件类型，如 user.created
+	HeaderDelivery  = "X-Webhook-Delivery"  //投递id，重试时不变，接收方可以用来去重
+	HeaderTimestamp = "X-Webhook-Timestamp" //发送时的 unix 秒数，参与签名
+	HeaderSignature = "X-Webhook-Signature" //sha256=<hex>
+)
+
+// Tolerance 接收方校验签名时允许的时间差，超过的请求当作重放
+var Tolerance = 5 * time.Minute
+
+// Sign 对 "时间戳.请求体" 做 HMAC-SHA256，返回 X-Webhook-Signature 的值
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 接收方校验签名和时间戳，timestamp、signature 是请求头里的值
+func Verify(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > Tolerance || d < -Tolerance {
+		return false
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body)))
+}
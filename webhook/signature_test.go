@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"user.created"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("secret", now.Unix(), body)
+	tests := []struct {
+		name, secret, timestamp, signature string
+		body                               []byte
+		now                                time.Time
+		want                               bool
+	}{
+		{"valid", "secret", ts, sig, body, now, true},
+		{"wrong secret", "other", ts, sig, body, now, false},
+		{"body changed", "secret", ts, sig, []byte(`{"type":"user.deleted"}`), now, false},
+		{"timestamp changed", "secret", strconv.FormatInt(now.Unix()+1, 10), sig, body, now, false},
+		{"bad timestamp", "secret", "abc", sig, body, now, false},
+		{"without prefix", "secret", ts, sig[len("sha256="):], body, now, false},
+		// 在允许的时间差以内
+		{"late", "secret", ts, sig, body, now.Add(Tolerance), true},
+		{"early", "secret", ts, sig, body, now.Add(-Tolerance), true},
+		// 超过时间差按重放处理
+		{"too late", "secret", ts, sig, body, now.Add(Tolerance + time.Second), false},
+		{"too early", "secret", ts, sig, body, now.Add(-Tolerance - time.Second), false},
+	}
+	for _, tt := range tests {
+		if got := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, tt.now); got != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}